@@ -12,6 +12,7 @@ import (
 
 func main() {
 	gatewayEndpoint := "http://127.0.0.1:8080"
+	types.RegisterDeviceControl(DeviceControl_IDENTIFY, "Identify", "Marks the device in the log")
 	mockProvider := NewMockProvider(types.DeviceType__GENERIC_DUMMY, 1)
 	mockProvider.FetchDevices(context.Background())
 	mockDriver, err := driver.NewDriver(mockProvider)
@@ -35,17 +36,14 @@ func main() {
 		return nil
 	})
 
+	mockService.SetDeviceRunner(func(ctx context.Context, device types.Device) {
+		NewMockDevice(device).Connect(ctx)
+	})
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	devices := mockDriver.GetDevices()
-	updateChan := make(chan types.Device, 100)
-	for _, device := range devices {
-		mockDevice := NewMockDevice(device, updateChan)
-		go mockDevice.Connect(ctx)
-	}
-
-	fmt.Print(mockService.Listen(ctx, 8090, updateChan))
+	fmt.Print(mockService.Listen(ctx, 8090))
 }
 
 func checkDevice(device *types.DeviceUpdate) *types.Error {
@@ -3,25 +3,28 @@ package main
 import (
 	"context"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/lukirs95/monika-gosdk/pkg/types"
 )
 
+const DeviceControl_IDENTIFY types.DeviceControl = "IDENTIFY"
+
 type MockDevice struct {
-	device        types.Device
-	triggerUpdate chan types.Device
+	device types.Device
 }
 
-func NewMockDevice(device types.Device, updateChan chan types.Device) *MockDevice {
+func NewMockDevice(device types.Device) *MockDevice {
 	mock := &MockDevice{
-		device:        device,
-		triggerUpdate: updateChan,
+		device: device,
 	}
 
 	device.AddAction(types.DeviceControl_BOOT, mock.deviceActionBoot)
 	device.AddAction(types.DeviceControl_SHUTDOWN, mock.deviceActionShutDown)
 	device.AddAction(types.DeviceControl_REBOOT, mock.deviceActionReboot)
+	device.AddAction(DeviceControl_IDENTIFY, mock.deviceActionIdentify)
+	device.SetRouteAction(mock.deviceActionRoute)
 	return mock
 }
 
@@ -31,7 +34,30 @@ func (mock *MockDevice) Connect(ctx context.Context) {
 	mockVideoModule.AddIOlet(types.NewIOlet("2", types.IOletType_IPVIDEOIN, "Video Input 2"))
 	mockVideoModule.AddIOlet(types.NewIOlet("3", types.IOletType_IPVIDEOIN, "Video Input 3"))
 	mockVideoModule.AddIOlet(types.NewIOlet("4", types.IOletType_IPVIDEOIN, "Video Input 4"))
-	mockVideoModule.AddIOlet(types.NewIOlet("5", types.IOletType_IPVIDEOOUT, "Video Output 1"))
+	mockVideoOutput := types.NewIOlet("5", types.IOletType_IPVIDEOOUT, "Video Output 1")
+	mockVideoOutput.(types.Streamer).SetStream(&types.StreamDescriptor{
+		SessionName: "Video Output 1",
+		Origin:      "192.168.1.10",
+		Media: []types.MediaStream{{
+			MediaType:   "video",
+			Destination: "239.100.1.1",
+			TTL:         64,
+			Source:      "192.168.1.10",
+			Port:        50000,
+			PayloadType: 96,
+			Encoding:    "raw",
+			ClockRate:   90000,
+			Width:       1920,
+			Height:      1080,
+			FrameRate:   "25",
+			Sampling:    "YCbCr-4:2:2",
+			Depth:       10,
+			Colorimetry: "BT709",
+			PTP:         &types.PTPReference{Version: "IEEE1588-2008", Grandmaster: "08-00-11-FF-FE-21-E1-B0", Domain: 127},
+			MediaClock:  "direct=0",
+		}},
+	})
+	mockVideoModule.AddIOlet(mockVideoOutput)
 	mockVideoModule.AddIOlet(types.NewIOlet("6", types.IOletType_IPVIDEOOUT, "Video Output 2"))
 	mockVideoModule.AddIOlet(types.NewIOlet("7", types.IOletType_IPVIDEOOUT, "Video Output 3"))
 	mockVideoModule.AddIOlet(types.NewIOlet("8", types.IOletType_IPVIDEOOUT, "Video Output 4"))
@@ -87,70 +113,60 @@ func (mock *MockDevice) Connect(ctx context.Context) {
 
 func (mock *MockDevice) mockUpdate(currentTime time.Time) {
 	mock.device.SetName(currentTime.Format("15:04:05"))
-	mock.triggerUpdate <- mock.device
 }
 
 func (mock *MockDevice) deviceActionBoot(ctx context.Context, device types.Device) error {
-	currentStatus := mock.device.GetStatus()
-	currentStatus.SetONLINE(true)
-	mock.device.SetStatus(currentStatus)
-	mock.triggerUpdate <- mock.device
+	mock.device.ModifyStatus(func(status *types.DeviceStatus) { status.SetONLINE(true) })
 	return nil
 }
 
 func (mock *MockDevice) deviceActionShutDown(ctx context.Context, device types.Device) error {
-	currentStatus := mock.device.GetStatus()
-	currentStatus.SetONLINE(false)
-	mock.device.SetStatus(currentStatus)
-	mock.triggerUpdate <- mock.device
+	mock.device.ModifyStatus(func(status *types.DeviceStatus) { status.SetONLINE(false) })
 	return nil
 }
 
 func (mock *MockDevice) deviceActionReboot(ctx context.Context, device types.Device) error {
-	currentStatus := mock.device.GetStatus()
-	currentStatus.SetONLINE(false)
-	mock.device.SetStatus(currentStatus)
-	mock.triggerUpdate <- mock.device
+	mock.device.ModifyStatus(func(status *types.DeviceStatus) { status.SetONLINE(false) })
 	go func(mock *MockDevice) {
 		time.Sleep(time.Second * 5)
-		currentStatus := mock.device.GetStatus()
-		currentStatus.SetONLINE(true)
-		mock.device.SetStatus(currentStatus)
-		mock.triggerUpdate <- mock.device
+		mock.device.ModifyStatus(func(status *types.DeviceStatus) { status.SetONLINE(true) })
 	}(mock)
 	return nil
 }
 
+func (mock *MockDevice) deviceActionIdentify(ctx context.Context, device types.Device) error {
+	log.Printf("Device %s identified", device.GetName())
+	return nil
+}
+
+// deviceActionRoute pretends the device switched the crosspoint right away.
+func (mock *MockDevice) deviceActionRoute(ctx context.Context, device types.Device, route types.Route) error {
+	device.SetRoute(route)
+	return nil
+}
+
 func (mock *MockDevice) moduleActionStart(ctx context.Context, module types.Module) error {
-	currentStatus := module.GetStatus()
-	currentStatus.SetOK(true)
-	module.SetStatus(currentStatus)
-	mock.triggerUpdate <- mock.device
+	module.ModifyStatus(func(status *types.ModuleStatus) { status.SetOK(true) })
 	return nil
 }
 
 func (mock *MockDevice) moduleActionStop(ctx context.Context, module types.Module) error {
-	currentStatus := module.GetStatus()
-	currentStatus.SetOK(false)
-	module.SetStatus(currentStatus)
-	mock.triggerUpdate <- mock.device
+	module.ModifyStatus(func(status *types.ModuleStatus) { status.SetOK(false) })
 	return nil
 }
 
 func (mock *MockDevice) ioletActionStart(ctx context.Context, iolet types.IOlet) error {
-	currentStatus := iolet.GetStatus()
-	currentStatus.SetRunning(true)
-	currentStatus.SetReceiving(true)
-	iolet.SetStatus(currentStatus)
-	mock.triggerUpdate <- mock.device
+	iolet.ModifyStatus(func(status *types.IOletStatus) {
+		status.SetRunning(true)
+		status.SetReceiving(true)
+	})
 	return nil
 }
 
 func (mock *MockDevice) ioletActionStop(ctx context.Context, iolet types.IOlet) error {
-	currentStatus := iolet.GetStatus()
-	currentStatus.SetRunning(false)
-	currentStatus.SetReceiving(false)
-	iolet.SetStatus(currentStatus)
-	mock.triggerUpdate <- mock.device
+	iolet.ModifyStatus(func(status *types.IOletStatus) {
+		status.SetRunning(false)
+		status.SetReceiving(false)
+	})
 	return nil
 }
@@ -24,7 +24,7 @@ func NewMockProvider(deviceType types.DeviceType, length int) provider.DevicePro
 }
 
 func (provider *MockProvider) FetchDevices(ctx context.Context) error {
-	for i := 0; i < provider.length; i++ {
+	for i := len(provider.devices); i < provider.length; i++ {
 		provider.devices = append(provider.devices, types.NewDevice(types.DeviceId(fmt.Sprint(i)), provider.deviceType, fmt.Sprintf("Mock Device %d", i)))
 	}
 	return nil
@@ -0,0 +1,83 @@
+package monika
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/lukirs95/monika-gosdk/pkg/types"
+)
+
+func (m *Monika) GroupList() ([]types.Group, error) {
+	var groups []types.Group
+	res, err := m.get("/api/group")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(res, &groups); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// GroupCreate creates a group and returns it with the id assigned by the
+// gateway.
+func (m *Monika) GroupCreate(name string) (*types.Group, error) {
+	var group types.Group
+	res, err := m.post("/api/group", &types.Group{Name: name})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(res, &group); err != nil {
+		return nil, err
+	}
+
+	return &group, nil
+}
+
+func (m *Monika) GroupRename(groupId int64, name string) error {
+	_, err := m.put(fmt.Sprintf("/api/group/%d", groupId), &types.Group{Id: groupId, Name: name})
+	return err
+}
+
+// GroupDelete deletes the group including its members.
+func (m *Monika) GroupDelete(groupId int64) error {
+	return m.delete(fmt.Sprintf("/api/group/%d", groupId))
+}
+
+func (m *Monika) GroupMemberList(groupId int64) ([]types.GroupMember, error) {
+	var members []types.GroupMember
+	res, err := m.get(fmt.Sprintf("/api/group/%d/member", groupId))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(res, &members); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// GroupMemberAdd adds the module of member to the group and returns the
+// member with the id assigned by the gateway.
+func (m *Monika) GroupMemberAdd(groupId int64, member *types.GroupMember) (*types.GroupMember, error) {
+	var added types.GroupMember
+	member.Group = groupId
+	res, err := m.post(fmt.Sprintf("/api/group/%d/member", groupId), member)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(res, &added); err != nil {
+		return nil, err
+	}
+
+	return &added, nil
+}
+
+func (m *Monika) GroupMemberDelete(groupId int64, memberId int64) error {
+	return m.delete(fmt.Sprintf("/api/group/%d/member/%d", groupId, memberId))
+}
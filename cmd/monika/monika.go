@@ -30,18 +30,44 @@ func NewMonika(endpoint string) *Monika {
 	}
 }
 
+func (m *Monika) get(path string) ([]byte, error) {
+	return m.request(http.MethodGet, path, nil)
+}
+
 func (m *Monika) post(path string, body any) ([]byte, error) {
+	return m.request(http.MethodPost, path, body)
+}
+
+func (m *Monika) put(path string, body any) ([]byte, error) {
+	return m.request(http.MethodPut, path, body)
+}
+
+// request sends body encoded as JSON, if not nil, and returns the response
+// body.
+func (m *Monika) request(method string, path string, body any) ([]byte, error) {
 	endpoint := fmt.Sprintf("%s%s", m.endpoint, path)
-	reqBody, err := json.Marshal(body)
+	var bodyReader io.Reader
+	if body != nil {
+		reqBody, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		bodyReader = bytes.NewReader(reqBody)
+	}
+
+	req, err := http.NewRequest(method, endpoint, bodyReader)
 	if err != nil {
 		return nil, err
 	}
-	bodyReader := bytes.NewReader(reqBody)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
-	res, err := m.client.Post(endpoint, "application/json", bodyReader)
+	res, err := m.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer res.Body.Close()
 
 	if res.StatusCode != 200 {
 		return nil, fmt.Errorf("status: %d, %s", res.StatusCode, res.Status)
@@ -61,6 +87,7 @@ func (m *Monika) delete(path string) error {
 	if err != nil {
 		return err
 	}
+	defer res.Body.Close()
 
 	if res.StatusCode != 200 {
 		return fmt.Errorf("status: %d, %s", res.StatusCode, res.Status)
@@ -0,0 +1,87 @@
+package monika
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lukirs95/monika-gosdk/pkg/types"
+)
+
+var errInvalidPassword = errors.New("password must be 10-32 characters without whitespace")
+
+func (m *Monika) UserList() ([]types.User, error) {
+	var users []types.User
+	res, err := m.get("/api/user")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(res, &users); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// UserMe returns the user the client is authenticated as.
+func (m *Monika) UserMe() (*types.User, error) {
+	var user types.User
+	res, err := m.get("/api/user/me")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(res, &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// UserCreate sanitizes and validates user before creating it, and returns
+// the user with the id assigned by the gateway.
+func (m *Monika) UserCreate(user *types.User) (*types.User, error) {
+	user.Username.Sanitize()
+	if !user.Username.Valid() {
+		return nil, fmt.Errorf("%s is not a valid username", user.Username)
+	}
+	if !user.Password.Valid() {
+		return nil, errInvalidPassword
+	}
+	if !user.Role.Valid() {
+		return nil, fmt.Errorf("%s is not a valid role", user.Role)
+	}
+
+	var created types.User
+	res, err := m.post("/api/user", user)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(res, &created); err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+func (m *Monika) UserSetRole(userId int64, role types.UserRole) error {
+	if !role.Valid() {
+		return fmt.Errorf("%s is not a valid role", role)
+	}
+	_, err := m.put(fmt.Sprintf("/api/user/%d/role", userId), &types.User{UserId: userId, Role: role})
+	return err
+}
+
+func (m *Monika) UserSetPassword(userId int64, password types.Password) error {
+	if !password.Valid() {
+		return errInvalidPassword
+	}
+	_, err := m.put(fmt.Sprintf("/api/user/%d/password", userId), &types.User{UserId: userId, Password: password})
+	return err
+}
+
+func (m *Monika) UserDelete(userId int64) error {
+	return m.delete(fmt.Sprintf("/api/user/%d", userId))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/lukirs95/monika-gosdk/pkg/driver"
+	"github.com/lukirs95/monika-gosdk/pkg/nut"
+	"github.com/lukirs95/monika-gosdk/pkg/provider"
+	"github.com/lukirs95/monika-gosdk/pkg/types"
+)
+
+// ups_driver monitors the GENERIC_USV devices of netbox through upsd. It is
+// configured by the environment or a .env file:
+//
+//	GATEWAY_ADDRESS, NETBOX_ADDRESS, NETBOX_APIKEY, NETBOX_DEVICETYPE_ID,
+//	UPS_NAME (default "ups"), UPS_USERNAME, UPS_PASSWORD
+func main() {
+	godotenv.Load(".env")
+	gatewayEndpoint := os.Getenv("GATEWAY_ADDRESS")
+	if gatewayEndpoint == "" {
+		gatewayEndpoint = "http://127.0.0.1:8080"
+	}
+	upsName := os.Getenv("UPS_NAME")
+	if upsName == "" {
+		upsName = "ups"
+	}
+	deviceTypeId, err := strconv.Atoi(os.Getenv("NETBOX_DEVICETYPE_ID"))
+	if err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+
+	upsProvider := provider.NewDeviceProviderNetbox(os.Getenv("NETBOX_ADDRESS"), os.Getenv("NETBOX_APIKEY"), types.DeviceType_GENERIC_USV, deviceTypeId)
+	if err := upsProvider.FetchDevices(context.Background()); err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+	upsDriver, err := driver.NewDriver(upsProvider)
+	if err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	upsService := driver.NewService(gatewayEndpoint, upsDriver, log.Default())
+	upsService.AddErrorCheckModule(nut.CheckModuleError)
+	upsService.SetDeviceRunner(func(ctx context.Context, device types.Device) {
+		ups := nut.NewUPS(device, upsName)
+		ups.SetLogin(os.Getenv("UPS_USERNAME"), os.Getenv("UPS_PASSWORD"))
+		ups.Run(ctx, 10*time.Second)
+	})
+	// picks up UPS added to or removed from netbox
+	upsService.SetRefreshInterval(5 * time.Minute)
+
+	fmt.Print(upsService.Listen(ctx, 8091))
+}
@@ -6,10 +6,18 @@ import (
 	"github.com/lukirs95/monika-gosdk/pkg/types"
 )
 
+// Driver serves the device trees of a driver. Elements are addressed by their
+// full path. Lookups fail with types.ErrDeviceNotFound,
+// types.ErrModuleNotFound or types.ErrIOletNotFound if an element of the path
+// does not exist, and with types.ErrTypeMismatch if it has another type than
+// the path says. Controls an element has no action for fail with
+// types.ErrControlNotSupported.
 type Driver interface {
 	DeviceDriver
 	ModuleDriver
 	IOletDriver
+	RoutingDriver
+	BulkDriver
 }
 
 type DeviceDriver interface {
@@ -18,33 +26,70 @@ type DeviceDriver interface {
 	// returns all devices the driver handles without the modules.
 	GetDevices() []types.Device
 	// returns one device based on the deviceId
-	GetDevice(deviceId types.DeviceId) types.Device
-	// RunDeviceControl executes the given control command
-	RunDeviceControl(ctx context.Context, deviceId types.DeviceId, cmd types.DeviceControl) error
+	GetDevice(deviceId types.DeviceId) (types.Device, error)
+	// RunDeviceControl executes the given control command, args are validated
+	// against the parameters of the control
+	RunDeviceControl(ctx context.Context, deviceId types.DeviceId, cmd types.DeviceControl, args types.Arguments) error
 	// returns the moduleTypes the driver has in the system
-	GetModuleTypes(deviceId types.DeviceId) []types.ModuleType
+	GetModuleTypes(deviceId types.DeviceId) ([]types.ModuleType, error)
+	// Refresh fetches the devices of the provider again and replaces the
+	// devices the driver handles. It fails, keeping the current devices, if
+	// the provider fails or its devices are not valid.
+	Refresh(ctx context.Context) (DeviceChanges, error)
+}
+
+// DeviceChanges lists the devices a Refresh added, retired and those whose
+// name or control address changed.
+type DeviceChanges struct {
+	Added   []types.Device
+	Retired []types.Device
+	Updated []types.Device
+}
+
+func (changes DeviceChanges) Empty() bool {
+	return len(changes.Added) == 0 && len(changes.Retired) == 0 && len(changes.Updated) == 0
 }
 
 type ModuleDriver interface {
 	// returns all modules the driver handles without IOlet's
-	GetModules(deviceId types.DeviceId) []types.Module
+	GetModules(deviceId types.DeviceId) ([]types.Module, error)
 	// returns all modules the driver handles based on the moduleType.
-	GetModulesByModuleType(deviceId types.DeviceId, moduleType types.ModuleType) []types.Module
+	GetModulesByModuleType(deviceId types.DeviceId, moduleType types.ModuleType) ([]types.Module, error)
 	// returns one module based on the moduleId
-	GetModule(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId) types.Module
-	// RunModuleControl executes the given control command
-	RunModuleControl(ctx context.Context, deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId, cmd types.ModuleControl) error
+	GetModule(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId) (types.Module, error)
+	// RunModuleControl executes the given control command, args are validated
+	// against the parameters of the control
+	RunModuleControl(ctx context.Context, deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId, cmd types.ModuleControl, args types.Arguments) error
 }
 
 type IOletDriver interface {
 	// returns the IOletTypes the driver has in the system
-	GetIOletTypes(deviceId types.DeviceId, moduleId types.ModuleId) []types.IOletType
+	GetIOletTypes(deviceId types.DeviceId, moduleId types.ModuleId) ([]types.IOletType, error)
 	// returns all IOlets the given module has
-	GetIOlets(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId) []types.IOlet
+	GetIOlets(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId) ([]types.IOlet, error)
 	// returns all IOlets the given module has based on ioletType
-	GetIOletsByIOletType(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId, ioletType types.IOletType) []types.IOlet
+	GetIOletsByIOletType(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId, ioletType types.IOletType) ([]types.IOlet, error)
 	// returns one IOlet based on the ioletId
-	GetIOlet(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId, ioLetType types.IOletType, ioLetId types.IOletId) types.IOlet
-	// RunIOletCommand executes the given control command
-	RunIOletCommand(ctx context.Context, deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId, ioLetType types.IOletType, ioLetId types.IOletId, cmd types.IOletControl) error
+	GetIOlet(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId, ioLetType types.IOletType, ioLetId types.IOletId) (types.IOlet, error)
+	// RunIOletCommand executes the given control command, args are validated
+	// against the parameters of the control
+	RunIOletCommand(ctx context.Context, deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId, ioLetType types.IOletType, ioLetId types.IOletId, cmd types.IOletControl, args types.Arguments) error
+}
+
+type BulkDriver interface {
+	// Select returns the paths of the elements selector selects
+	Select(selector types.ElementSelector) ([]types.ElementPath, error)
+	// RunBulkControl runs a control on many elements at once. It only fails if
+	// bulk is not valid, the outcome for every element is in the report.
+	RunBulkControl(ctx context.Context, bulk types.BulkControl) (types.BulkControlReport, error)
+}
+
+type RoutingDriver interface {
+	// returns all routes whose destination is on the given device
+	GetRoutes(deviceId types.DeviceId) ([]types.Route, error)
+	// SetRoute routes the source to the destination using the route action of
+	// the destination's device
+	SetRoute(ctx context.Context, route types.Route) error
+	// ClearRoute disconnects the destination
+	ClearRoute(ctx context.Context, destination types.IOletAddress) error
 }
@@ -0,0 +1,105 @@
+package driver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lukirs95/monika-gosdk/pkg/types"
+)
+
+func (m *driverImpl) Select(selector types.ElementSelector) ([]types.ElementPath, error) {
+	if err := selector.Valid(); err != nil {
+		return nil, err
+	}
+	return selector.Select(m.GetDevices()), nil
+}
+
+// RunBulkControl runs at most bulk.Concurrency controls at once. A control
+// which does not return within bulk.Timeout() is reported as failed, even if
+// its action ignores the context and keeps running.
+func (m *driverImpl) RunBulkControl(ctx context.Context, bulk types.BulkControl) (types.BulkControlReport, error) {
+	if err := bulk.Valid(); err != nil {
+		return types.BulkControlReport{}, err
+	}
+	paths := append([]types.ElementPath(nil), bulk.Targets...)
+	if bulk.Selector != nil {
+		paths = append(paths, bulk.Selector.Select(m.GetDevices())...)
+	}
+	concurrency := bulk.Concurrency
+	if concurrency == 0 {
+		concurrency = types.BULK_CONCURRENCY
+	}
+	timeout := bulk.Timeout()
+
+	results := make([]types.ElementResult, len(paths))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, path types.ElementPath) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			start := time.Now()
+			err := m.runControl(ctx, timeout, path, bulk.Control, bulk.Arguments)
+			results[i] = types.ElementResult{Path: path, DurationMs: time.Since(start).Milliseconds(), Err: err}
+		}(i, path)
+	}
+	wg.Wait()
+
+	report := types.BulkControlReport{Control: bulk.Control, Results: results}
+	for i := range report.Results {
+		if err := report.Results[i].Err; err != nil {
+			report.Results[i].Error = err.Error()
+			report.Failed++
+		} else {
+			report.Succeeded++
+		}
+	}
+	return report, nil
+}
+
+// runControl runs control on the element at path, giving up after timeout.
+func (m *driverImpl) runControl(ctx context.Context, timeout time.Duration, path types.ElementPath, control string, args types.Arguments) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- runControl(ctx, m, path, control, args)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runControl runs control on the element at path using the control of its
+// level.
+func runControl(ctx context.Context, driver Driver, path types.ElementPath, control string, args types.Arguments) error {
+	switch path.Level() {
+	case types.ElementLevel_IOLET:
+		return driver.RunIOletCommand(ctx, path.DeviceId, path.ModuleType, path.ModuleId, path.IOletType, path.IOletId, types.IOletControl(control), args)
+	case types.ElementLevel_MODULE:
+		return driver.RunModuleControl(ctx, path.DeviceId, path.ModuleType, path.ModuleId, types.ModuleControl(control), args)
+	}
+	return driver.RunDeviceControl(ctx, path.DeviceId, types.DeviceControl(control), args)
+}
+
+// lookupElement fails like the controls of the element at path would if it
+// does not exist.
+func lookupElement(driver Driver, path types.ElementPath) error {
+	var err error
+	switch path.Level() {
+	case types.ElementLevel_IOLET:
+		_, err = driver.GetIOlet(path.DeviceId, path.ModuleType, path.ModuleId, path.IOletType, path.IOletId)
+	case types.ElementLevel_MODULE:
+		_, err = driver.GetModule(path.DeviceId, path.ModuleType, path.ModuleId)
+	default:
+		_, err = driver.GetDevice(path.DeviceId)
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lukirs95/monika-gosdk/pkg/types"
+)
+
+func TestRunBulkControl(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	start := func(ctx context.Context, iolet types.IOlet) error {
+		now := running.Add(1)
+		defer running.Add(-1)
+		for {
+			max := maxRunning.Load()
+			if now <= max || maxRunning.CompareAndSwap(max, now) {
+				break
+			}
+		}
+		if iolet.GetId() == "slow" {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}
+	module := types.NewModule("3", types.ModuleType_AV, "Channel 3")
+	for i := 0; i < 10; i++ {
+		iolet := types.NewIOlet(types.IOletId(fmt.Sprint(i)), types.IOletType_IPVIDEOIN, fmt.Sprintf("Video In %d", i))
+		iolet.AddAction(types.IOletControl_START, start)
+		module.AddIOlet(iolet)
+	}
+	slow := types.NewIOlet("slow", types.IOletType_IPVIDEOIN, "Slow")
+	slow.AddAction(types.IOletControl_START, start)
+	module.AddIOlet(slow)
+	module.AddIOlet(types.NewIOlet("out", types.IOletType_IPVIDEOOUT, "Video Out"))
+	device := types.NewDevice("1", types.DeviceType__GENERIC_DUMMY, "Encoder")
+	device.AddModule(module)
+	driver, err := NewDriver(&staticProvider{devices: []types.Device{device}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := driver.RunBulkControl(context.Background(), types.BulkControl{
+		Control: string(types.IOletControl_START),
+		Targets: []types.ElementPath{{DeviceId: "2", ModuleId: "3", IOletId: "1"}},
+		Selector: &types.ElementSelector{
+			Level:     types.ElementLevel_IOLET,
+			ModuleIds: []types.ModuleId{"3"},
+		},
+		Concurrency: 3,
+		TimeoutMs:   50,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) != 13 || report.Succeeded != 10 || report.Failed != 3 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if max := maxRunning.Load(); max > 3 {
+		t.Errorf("at most 3 controls should run at once, %d did", max)
+	}
+	failed := make(map[types.ElementPath]error)
+	for _, result := range report.Results {
+		if result.Err != nil {
+			failed[result.Path] = result.Err
+		}
+	}
+	if statusOf(failed[types.ElementPath{DeviceId: "2", ModuleId: "3", IOletId: "1"}]) != http.StatusNotFound {
+		t.Error("missing device should be reported as not found")
+	}
+	if statusOf(failed[types.ElementPath{DeviceId: "1", ModuleType: types.ModuleType_AV, ModuleId: "3", IOletType: types.IOletType_IPVIDEOIN, IOletId: "slow"}]) != http.StatusGatewayTimeout {
+		t.Error("slow control should time out")
+	}
+	if statusOf(failed[types.ElementPath{DeviceId: "1", ModuleType: types.ModuleType_AV, ModuleId: "3", IOletType: types.IOletType_IPVIDEOOUT, IOletId: "out"}]) != http.StatusBadRequest {
+		t.Error("iolet without action should fail")
+	}
+
+	if _, err := driver.RunBulkControl(context.Background(), types.BulkControl{Control: "START"}); err == nil {
+		t.Error("bulk control without elements should fail")
+	}
+}
+
+func TestHandleBulkControl(t *testing.T) {
+	service := testService(t)
+	body, _ := json.Marshal(types.BulkControl{
+		Control: string(types.IOletControl_START),
+		Targets: []types.ElementPath{
+			{DeviceId: "1", ModuleId: "1", IOletId: "1"},
+			{DeviceId: "1", ModuleType: types.ModuleType_GPIO, ModuleId: "1", IOletId: "1"},
+		},
+	})
+	recorder := httptest.NewRecorder()
+	service.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/_bulk", bytes.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", recorder.Code, recorder.Body)
+	}
+	var report types.BulkControlReport
+	if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Succeeded != 1 || report.Failed != 1 || report.Results[0].Status != http.StatusOK || report.Results[1].Status != http.StatusConflict || report.Results[1].Error == "" {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	recorder = httptest.NewRecorder()
+	service.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/_bulk", bytes.NewReader([]byte(`{"control": "START"}`))))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("bulk control without elements should be rejected, got %d", recorder.Code)
+	}
+}
@@ -3,22 +3,59 @@ package driver
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/lukirs95/monika-gosdk/pkg/provider"
 	"github.com/lukirs95/monika-gosdk/pkg/types"
 )
 
 type driverImpl struct {
-	devices  []types.Device
-	provider provider.DeviceProvider
+	// mutex guards devices and devicesById, refresh serializes Refresh
+	mutex   sync.RWMutex
+	refresh sync.Mutex
+	devices []types.Device
+	// devicesById indexes devices by the id they had when indexed
+	devicesById map[types.DeviceId]types.Device
+	provider    provider.DeviceProvider
 }
 
+// providerFields are the fields of a device a provider updates in place.
+type providerFields struct {
+	name        string
+	controlIP   string
+	controlPort int
+}
+
+func providerFieldsOf(device types.Device) providerFields {
+	return providerFields{
+		name:        device.GetName(),
+		controlIP:   device.GetControlIP(),
+		controlPort: device.GetControlPort(),
+	}
+}
+
+func indexDevices(devices []types.Device) map[types.DeviceId]types.Device {
+	devicesById := make(map[types.DeviceId]types.Device)
+	for _, device := range devices {
+		if _, ok := devicesById[device.GetId()]; !ok {
+			devicesById[device.GetId()] = device
+		}
+	}
+	return devicesById
+}
+
+// NewDriver fails if the devices of provider are not valid, see
+// types.Validate.
 func NewDriver(provider provider.DeviceProvider) (Driver, error) {
 	devices := provider.GetDevices()
+	if err := types.Validate(devices); err != nil {
+		return nil, err
+	}
 
 	return &driverImpl{
-		devices:  devices,
-		provider: provider,
+		devices:     devices,
+		devicesById: indexDevices(devices),
+		provider:    provider,
 	}, nil
 }
 
@@ -27,152 +64,225 @@ func (driver *driverImpl) GetDeviceType() types.DeviceType {
 }
 
 func (driver *driverImpl) GetDevices() []types.Device {
-	return driver.devices
+	driver.mutex.RLock()
+	defer driver.mutex.RUnlock()
+	devices := make([]types.Device, len(driver.devices))
+	copy(devices, driver.devices)
+	return devices
 }
 
-func (m *driverImpl) GetDevice(deviceId types.DeviceId) types.Device {
-	for _, device := range m.devices {
-		if device.GetId() == deviceId {
-			return device
+func (driver *driverImpl) Refresh(ctx context.Context) (DeviceChanges, error) {
+	driver.refresh.Lock()
+	defer driver.refresh.Unlock()
+
+	// providers update known devices in place, so their fields are compared
+	// to a copy taken before the fetch
+	before := make(map[types.Device]providerFields)
+	for _, device := range driver.GetDevices() {
+		before[device] = providerFieldsOf(device)
+	}
+
+	if err := driver.provider.FetchDevices(ctx); err != nil {
+		return DeviceChanges{}, err
+	}
+	devices := driver.provider.GetDevices()
+	if err := types.Validate(devices); err != nil {
+		return DeviceChanges{}, err
+	}
+
+	var changes DeviceChanges
+	for _, device := range devices {
+		fields, ok := before[device]
+		if !ok {
+			changes.Added = append(changes.Added, device)
+			continue
+		}
+		delete(before, device)
+		if fields != providerFieldsOf(device) {
+			changes.Updated = append(changes.Updated, device)
 		}
 	}
-	return nil
-}
-func (m *driverImpl) RunDeviceControl(ctx context.Context, deviceId types.DeviceId, cmd types.DeviceControl) error {
-	for _, device := range m.devices {
-		if device.GetId() == deviceId {
-			return device.FireAction(ctx, cmd)
+	for _, device := range driver.GetDevices() {
+		if _, ok := before[device]; ok {
+			changes.Retired = append(changes.Retired, device)
 		}
 	}
-	return fmt.Errorf("device not found")
+
+	driver.mutex.Lock()
+	driver.devices = devices
+	driver.devicesById = indexDevices(devices)
+	driver.mutex.Unlock()
+	return changes, nil
 }
 
-func (m *driverImpl) GetModuleTypes(deviceId types.DeviceId) []types.ModuleType {
+func (m *driverImpl) GetDevice(deviceId types.DeviceId) (types.Device, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if device, ok := m.devicesById[deviceId]; ok && device.GetId() == deviceId {
+		return device, nil
+	}
+	// the id of a device may have changed after it was indexed
 	for _, device := range m.devices {
 		if device.GetId() == deviceId {
-			return device.GetModuleTypes()
+			return device, nil
 		}
 	}
-	return nil
+	return nil, fmt.Errorf("%w: %s", types.ErrDeviceNotFound, deviceId)
 }
 
-func (m *driverImpl) GetModules(deviceId types.DeviceId) []types.Module {
-	for _, device := range m.devices {
-		if device.GetId() == deviceId {
-			return device.GetModules()
-		}
+// getModule returns the module at the path, any moduleType matches if it is
+// empty.
+func (m *driverImpl) getModule(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId) (types.Module, error) {
+	device, err := m.GetDevice(deviceId)
+	if err != nil {
+		return nil, err
+	}
+	module := device.GetModule(moduleId)
+	if module == nil {
+		return nil, fmt.Errorf("%w: %s/%s", types.ErrModuleNotFound, deviceId, moduleId)
+	}
+	if moduleType != "" && module.GetType() != moduleType {
+		return nil, fmt.Errorf("%w: module %s/%s is %s, not %s", types.ErrTypeMismatch, deviceId, moduleId, module.GetType(), moduleType)
 	}
-	return nil
+	return module, nil
 }
 
-func (m *driverImpl) GetModulesByModuleType(deviceId types.DeviceId, moduleType types.ModuleType) []types.Module {
-	for _, device := range m.devices {
-		if device.GetId() == deviceId {
-			return device.GetModulesByType(moduleType)
-		}
+// getIOlet returns the iolet at the path, any type matches if it is empty.
+func (m *driverImpl) getIOlet(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId, ioletType types.IOletType, ioletId types.IOletId) (types.IOlet, error) {
+	module, err := m.getModule(deviceId, moduleType, moduleId)
+	if err != nil {
+		return nil, err
+	}
+	iolet := module.GetIOlet(ioletId)
+	if iolet == nil {
+		return nil, fmt.Errorf("%w: %s/%s/%s", types.ErrIOletNotFound, deviceId, moduleId, ioletId)
+	}
+	if ioletType != "" && iolet.GetType() != ioletType {
+		return nil, fmt.Errorf("%w: iolet %s/%s/%s is %s, not %s", types.ErrTypeMismatch, deviceId, moduleId, ioletId, iolet.GetType(), ioletType)
 	}
-	return nil
+	return iolet, nil
 }
 
-func (m *driverImpl) GetModule(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId) types.Module {
-	for _, device := range m.devices {
-		if device.GetId() == deviceId {
-			return device.GetModule(moduleId)
-		}
+func (m *driverImpl) RunDeviceControl(ctx context.Context, deviceId types.DeviceId, cmd types.DeviceControl, args types.Arguments) error {
+	device, err := m.GetDevice(deviceId)
+	if err != nil {
+		return err
 	}
-	return nil
+	return device.FireActionWithArguments(ctx, cmd, args)
 }
 
-func (m *driverImpl) RunModuleControl(ctx context.Context, deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId, cmd types.ModuleControl) error {
-	var module types.Module
-	for _, device := range m.devices {
-		if device.GetId() == deviceId {
-			module = device.GetModule(moduleId)
-		}
+func (m *driverImpl) GetModuleTypes(deviceId types.DeviceId) ([]types.ModuleType, error) {
+	device, err := m.GetDevice(deviceId)
+	if err != nil {
+		return nil, err
 	}
+	return device.GetModuleTypes(), nil
+}
 
-	if module == nil {
-		return fmt.Errorf("module not found")
+func (m *driverImpl) GetModules(deviceId types.DeviceId) ([]types.Module, error) {
+	device, err := m.GetDevice(deviceId)
+	if err != nil {
+		return nil, err
 	}
-
-	return module.FireAction(ctx, cmd)
+	return device.GetModules(), nil
 }
 
-func (m *driverImpl) GetIOletTypes(deviceId types.DeviceId, moduleId types.ModuleId) []types.IOletType {
-	var module types.Module
-	for _, device := range m.devices {
-		if device.GetId() == deviceId {
-			module = device.GetModule(moduleId)
-		}
+func (m *driverImpl) GetModulesByModuleType(deviceId types.DeviceId, moduleType types.ModuleType) ([]types.Module, error) {
+	device, err := m.GetDevice(deviceId)
+	if err != nil {
+		return nil, err
 	}
+	return device.GetModulesByType(moduleType), nil
+}
 
-	if module == nil {
-		return nil
+func (m *driverImpl) GetModule(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId) (types.Module, error) {
+	return m.getModule(deviceId, moduleType, moduleId)
+}
+
+func (m *driverImpl) RunModuleControl(ctx context.Context, deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId, cmd types.ModuleControl, args types.Arguments) error {
+	module, err := m.getModule(deviceId, moduleType, moduleId)
+	if err != nil {
+		return err
 	}
 
-	return module.GetIOletTypes()
+	return module.FireActionWithArguments(ctx, cmd, args)
 }
 
-func (m *driverImpl) GetIOlets(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId) []types.IOlet {
-	var module types.Module
-	for _, device := range m.devices {
-		if device.GetId() == deviceId {
-			module = device.GetModule(moduleId)
-		}
+func (m *driverImpl) GetIOletTypes(deviceId types.DeviceId, moduleId types.ModuleId) ([]types.IOletType, error) {
+	module, err := m.getModule(deviceId, "", moduleId)
+	if err != nil {
+		return nil, err
 	}
 
-	if module == nil {
-		return nil
+	return module.GetIOletTypes(), nil
+}
+
+func (m *driverImpl) GetIOlets(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId) ([]types.IOlet, error) {
+	module, err := m.getModule(deviceId, moduleType, moduleId)
+	if err != nil {
+		return nil, err
 	}
 
-	return module.GetIOlets()
+	return module.GetIOlets(), nil
 }
 
-func (m *driverImpl) GetIOletsByIOletType(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId, ioletType types.IOletType) []types.IOlet {
-	var module types.Module
-	for _, device := range m.devices {
-		if device.GetId() == deviceId {
-			module = device.GetModule(moduleId)
-		}
+func (m *driverImpl) GetIOletsByIOletType(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId, ioletType types.IOletType) ([]types.IOlet, error) {
+	module, err := m.getModule(deviceId, moduleType, moduleId)
+	if err != nil {
+		return nil, err
 	}
 
-	if module == nil {
-		return nil
-	}
+	return module.GetIOletsByType(ioletType), nil
+}
 
-	return module.GetIOletsByType(ioletType)
+func (m *driverImpl) GetIOlet(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId, ioletType types.IOletType, ioletId types.IOletId) (types.IOlet, error) {
+	return m.getIOlet(deviceId, moduleType, moduleId, ioletType, ioletId)
 }
 
-func (m *driverImpl) GetIOlet(deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId, ioletType types.IOletType, ioletId types.IOletId) types.IOlet {
-	var module types.Module
-	for _, device := range m.devices {
-		if device.GetId() == deviceId {
-			module = device.GetModule(moduleId)
-		}
+func (m *driverImpl) RunIOletCommand(ctx context.Context, deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId, ioletType types.IOletType, ioletId types.IOletId, cmd types.IOletControl, args types.Arguments) error {
+	iolet, err := m.getIOlet(deviceId, moduleType, moduleId, ioletType, ioletId)
+	if err != nil {
+		return err
 	}
 
-	if module == nil {
-		return nil
+	return iolet.FireActionWithArguments(ctx, cmd, args)
+}
+
+func (m *driverImpl) GetRoutes(deviceId types.DeviceId) ([]types.Route, error) {
+	device, err := m.GetDevice(deviceId)
+	if err != nil {
+		return nil, err
 	}
+	return device.GetRoutes(), nil
+}
 
-	return module.GetIOlet(ioletId)
+// findIOlet returns the iolet at address if its device is handled by this
+// driver.
+func (m *driverImpl) findIOlet(address types.IOletAddress) (types.IOlet, error) {
+	return m.getIOlet(address.DeviceId, "", address.ModuleId, "", address.IOletId)
 }
 
-func (m *driverImpl) RunIOletCommand(ctx context.Context, deviceId types.DeviceId, moduleType types.ModuleType, moduleId types.ModuleId, ioletType types.IOletType, ioletId types.IOletId, cmd types.IOletControl) error {
-	var module types.Module
-	for _, device := range m.devices {
-		if device.GetId() == deviceId {
-			module = device.GetModule(moduleId)
-		}
+func (m *driverImpl) SetRoute(ctx context.Context, route types.Route) error {
+	destinationDevice, err := m.GetDevice(route.Destination.DeviceId)
+	if err != nil {
+		return err
 	}
 
-	if module == nil {
-		return nil
+	if _, err := m.findIOlet(route.Destination); err != nil {
+		return fmt.Errorf("destination: %w", err)
 	}
-
-	if iolet := module.GetIOlet(ioletId); iolet != nil {
-		return iolet.FireAction(ctx, cmd)
+	// sources on devices of other drivers can not be checked
+	if route.Connected() {
+		if _, err := m.GetDevice(route.Source.DeviceId); err == nil {
+			if _, err := m.findIOlet(route.Source); err != nil {
+				return fmt.Errorf("source: %w", err)
+			}
+		}
 	}
 
-	return fmt.Errorf("iolet not found")
+	return destinationDevice.FireRouteAction(ctx, route)
+}
+
+func (m *driverImpl) ClearRoute(ctx context.Context, destination types.IOletAddress) error {
+	return m.SetRoute(ctx, types.Route{Destination: destination})
 }
@@ -0,0 +1,121 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/lukirs95/monika-gosdk/pkg/types"
+)
+
+type staticProvider struct {
+	devices []types.Device
+	// fetch is called by FetchDevices, e.g. to update devices in place
+	fetch func()
+}
+
+func (provider *staticProvider) FetchDevices(ctx context.Context) error {
+	if provider.fetch != nil {
+		provider.fetch()
+	}
+	return nil
+}
+
+func (provider *staticProvider) GetDevices() []types.Device {
+	return provider.devices
+}
+
+func (provider *staticProvider) GetDeviceType() types.DeviceType {
+	return types.DeviceType__GENERIC_DUMMY
+}
+
+func benchmarkDriver(b *testing.B, devices int) Driver {
+	provider := &staticProvider{}
+	for i := 0; i < devices; i++ {
+		device := types.NewDevice(types.DeviceId(fmt.Sprint(i)), types.DeviceType__GENERIC_DUMMY, fmt.Sprintf("Device %d", i))
+		for j := 0; j < 8; j++ {
+			module := types.NewModule(types.ModuleId(fmt.Sprint(j)), types.ModuleType_AV, fmt.Sprintf("Module %d", j))
+			for k := 0; k < 64; k++ {
+				module.AddIOlet(types.NewIOlet(types.IOletId(fmt.Sprint(k)), types.IOletType_IPVIDEOIN, fmt.Sprintf("IOlet %d", k)))
+			}
+			device.AddModule(module)
+		}
+		provider.devices = append(provider.devices, device)
+	}
+	driver, err := NewDriver(provider)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return driver
+}
+
+func BenchmarkDriverGetIOlet(b *testing.B) {
+	driver := benchmarkDriver(b, 500)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := driver.GetIOlet("499", types.ModuleType_AV, "7", types.IOletType_IPVIDEOIN, "63"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDriverRunIOletCommand(b *testing.B) {
+	driver := benchmarkDriver(b, 500)
+	iolet, err := driver.GetIOlet("499", types.ModuleType_AV, "7", types.IOletType_IPVIDEOIN, "63")
+	if err != nil {
+		b.Fatal(err)
+	}
+	iolet.AddAction(types.IOletControl_START, func(ctx context.Context, iolet types.IOlet) error {
+		return nil
+	})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := driver.RunIOletCommand(context.Background(), "499", types.ModuleType_AV, "7", types.IOletType_IPVIDEOIN, "63", types.IOletControl_START, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDriverRefresh(t *testing.T) {
+	kept := types.NewDevice("1", types.DeviceType__GENERIC_DUMMY, "Encoder")
+	moved := types.NewDevice("2", types.DeviceType__GENERIC_DUMMY, "Decoder")
+	retired := types.NewDevice("3", types.DeviceType__GENERIC_DUMMY, "Monitor")
+	provider := &staticProvider{devices: []types.Device{kept, moved, retired}}
+	driver, err := NewDriver(provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	added := types.NewDevice("4", types.DeviceType__GENERIC_DUMMY, "Camera")
+	provider.devices = []types.Device{kept, moved, added}
+	changes, err := driver.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Added) != 1 || changes.Added[0] != added || len(changes.Retired) != 1 || changes.Retired[0] != retired || len(changes.Updated) != 0 {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+	if _, err := driver.GetDevice("3"); err == nil {
+		t.Error("retired device should not be found")
+	}
+	if device, err := driver.GetDevice("4"); err != nil || device != added {
+		t.Error("added device should be found")
+	}
+
+	provider.fetch = func() { moved.SetName("Decoder 2") }
+	changes, err = driver.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Updated) != 1 || changes.Updated[0] != moved || len(changes.Added) != 0 || len(changes.Retired) != 0 {
+		t.Errorf("renamed device should be updated, got %+v", changes)
+	}
+
+	provider.devices = append(provider.devices, types.NewDevice("", types.DeviceType__GENERIC_DUMMY, "Invalid"))
+	if _, err := driver.Refresh(context.Background()); err == nil {
+		t.Error("refresh to invalid devices should fail")
+	}
+	if len(driver.GetDevices()) != 3 {
+		t.Error("failed refresh should keep the devices")
+	}
+}
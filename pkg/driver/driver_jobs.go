@@ -0,0 +1,286 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lukirs95/monika-gosdk/pkg/types"
+)
+
+type job struct {
+	types.Job
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// errShutdown is returned when submitting a job after the service stopped.
+var errShutdown = errors.New("service is shutting down")
+
+// jobQueue keeps the jobs of a Service. Jobs wait for one of the slots before
+// they run.
+type jobQueue struct {
+	mutex sync.Mutex
+	jobs  []*job
+	slots chan struct{}
+	// ctx is the parent of the contexts of all jobs, stop cancels it on
+	// shutdown
+	ctx     context.Context
+	stop    context.CancelFunc
+	running sync.WaitGroup
+}
+
+func newJobQueue(concurrency int) *jobQueue {
+	ctx, stop := context.WithCancel(context.Background())
+	return &jobQueue{
+		jobs:  make([]*job, 0),
+		slots: make(chan struct{}, concurrency),
+		ctx:   ctx,
+		stop:  stop,
+	}
+}
+
+func newJobId() types.JobId {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return types.JobId(hex.EncodeToString(id))
+}
+
+// add queues a job for request and forgets jobs finished before
+// JOB_RETENTION. The job has to call running.Done when it returns.
+func (queue *jobQueue) add(request types.JobRequest) (types.Job, context.Context, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if queue.ctx.Err() != nil {
+		return types.Job{}, nil, errShutdown
+	}
+	ctx, cancel := context.WithCancel(queue.ctx)
+	queue.running.Add(1)
+	now := time.Now()
+	queue.jobs = slices.DeleteFunc(queue.jobs, func(job *job) bool {
+		return job.Finished != nil && now.Sub(*job.Finished) > types.JOB_RETENTION
+	})
+	queued := &job{
+		Job: types.Job{
+			Id:      newJobId(),
+			Path:    request.Path,
+			Control: request.Control,
+			State:   types.JobState_QUEUED,
+			Created: now,
+		},
+		cancel: cancel,
+	}
+	queue.jobs = append(queue.jobs, queued)
+	return queued.Job, ctx, nil
+}
+
+// shutdown cancels all jobs and waits until their actions returned.
+func (queue *jobQueue) shutdown() {
+	queue.mutex.Lock()
+	for _, job := range queue.jobs {
+		if !job.State.Done() {
+			job.cancelled = true
+		}
+	}
+	queue.stop()
+	queue.mutex.Unlock()
+	queue.running.Wait()
+}
+
+// find expects the caller to hold the lock.
+func (queue *jobQueue) find(id types.JobId) (*job, error) {
+	index := slices.IndexFunc(queue.jobs, func(job *job) bool { return job.Id == id })
+	if index < 0 {
+		return nil, fmt.Errorf("%w: %s", types.ErrJobNotFound, id)
+	}
+	return queue.jobs[index], nil
+}
+
+func (queue *jobQueue) get(id types.JobId) (types.Job, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	job, err := queue.find(id)
+	if err != nil {
+		return types.Job{}, err
+	}
+	return job.Job, nil
+}
+
+func (queue *jobQueue) list() []types.Job {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	jobs := make([]types.Job, 0, len(queue.jobs))
+	for _, job := range queue.jobs {
+		jobs = append(jobs, job.Job)
+	}
+	return jobs
+}
+
+// start marks a queued job running, it fails if the job was cancelled while
+// queued.
+func (queue *jobQueue) start(id types.JobId) bool {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	job, err := queue.find(id)
+	if err != nil || job.State != types.JobState_QUEUED {
+		return false
+	}
+	now := time.Now()
+	job.State = types.JobState_RUNNING
+	job.Started = &now
+	return true
+}
+
+func (queue *jobQueue) progress(id types.JobId, progress float64, message string) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if job, err := queue.find(id); err == nil && job.State == types.JobState_RUNNING {
+		job.Progress = progress
+		job.Message = message
+	}
+}
+
+// finish ends a running job with the outcome of its control.
+func (queue *jobQueue) finish(id types.JobId, err error) (types.Job, bool) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	job, findErr := queue.find(id)
+	if findErr != nil || job.State.Done() {
+		return types.Job{}, false
+	}
+	switch {
+	case job.cancelled:
+		job.State = types.JobState_CANCELLED
+	case err != nil:
+		job.State = types.JobState_FAILED
+		job.Error = err.Error()
+	default:
+		job.State = types.JobState_SUCCEEDED
+		job.Progress = 1
+	}
+	now := time.Now()
+	job.Finished = &now
+	job.cancel()
+	return job.Job, true
+}
+
+// cancelJob cancels the context of a job. Queued jobs are cancelled right
+// away, running jobs when their action returns.
+func (queue *jobQueue) cancelJob(id types.JobId) (types.Job, bool, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	job, err := queue.find(id)
+	if err != nil {
+		return types.Job{}, false, err
+	}
+	if job.State.Done() {
+		return job.Job, false, fmt.Errorf("%w: %s is %s", types.ErrJobDone, id, job.State)
+	}
+	job.cancelled = true
+	job.cancel()
+	if job.State != types.JobState_QUEUED {
+		return job.Job, false, nil
+	}
+	now := time.Now()
+	job.State = types.JobState_CANCELLED
+	job.Finished = &now
+	return job.Job, true, nil
+}
+
+// SubmitJob runs a control as a job, which is not bound to the request that
+// submitted it but ends with the Listen of the service. It fails right away
+// if the element at the path does not exist.
+func (service *Service) SubmitJob(request types.JobRequest) (types.Job, error) {
+	if err := request.Valid(); err != nil {
+		return types.Job{}, err
+	}
+	if err := lookupElement(service.driver, request.Path); err != nil {
+		return types.Job{}, err
+	}
+
+	job, ctx, err := service.jobs.add(request)
+	if err != nil {
+		return types.Job{}, err
+	}
+	go service.runJob(ctx, job.Id, request)
+	return job, nil
+}
+
+func (service *Service) runJob(ctx context.Context, id types.JobId, request types.JobRequest) {
+	defer service.jobs.running.Done()
+	select {
+	case service.jobs.slots <- struct{}{}:
+		defer func() { <-service.jobs.slots }()
+	case <-ctx.Done():
+		// cancelled or shut down while queued
+		if job, ok := service.jobs.finish(id, ctx.Err()); ok {
+			service.reportJob(&job)
+		}
+		return
+	}
+	if !service.jobs.start(id) {
+		return
+	}
+	if timeout := request.Timeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ctx = types.WithProgressReporter(ctx, func(progress float64, message string) {
+		service.jobs.progress(id, progress, message)
+	})
+	err := runControl(ctx, service.driver, request.Path, request.Control, request.Arguments)
+	if job, ok := service.jobs.finish(id, err); ok {
+		service.reportJob(&job)
+	}
+}
+
+// Jobs lists the queued, running and recently finished jobs in the order
+// they were submitted.
+func (service *Service) Jobs() []types.Job {
+	return service.jobs.list()
+}
+
+func (service *Service) Job(id types.JobId) (types.Job, error) {
+	return service.jobs.get(id)
+}
+
+// CancelJob cancels a queued or running job. The action of a running job
+// should return once its context is done, the job is cancelled when it does.
+func (service *Service) CancelJob(id types.JobId) (types.Job, error) {
+	job, cancelled, err := service.jobs.cancelJob(id)
+	if err != nil {
+		return job, err
+	}
+	if cancelled {
+		service.reportJob(&job)
+	}
+	return job, nil
+}
+
+// reportJob tells the gateway that a job finished.
+func (service *Service) reportJob(job *types.Job) {
+	body, err := json.Marshal(job)
+	if err != nil {
+		service.logger.Print(err)
+		return
+	}
+	res, err := http.Post(fmt.Sprintf("%s/api/notify/job", service.gateway), "application/json", bytes.NewReader(body))
+	if err != nil {
+		service.logger.Print(err)
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		service.logger.Print("could not report job: ", res.Status)
+	}
+}
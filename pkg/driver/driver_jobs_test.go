@@ -0,0 +1,184 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lukirs95/monika-gosdk/pkg/types"
+)
+
+func jobService(t *testing.T, reboot types.DeviceAction) (*Service, func() []types.Job) {
+	var mutex sync.Mutex
+	reported := make([]types.Job, 0)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var job types.Job
+		if r.URL.Path == "/api/notify/job" && json.NewDecoder(r.Body).Decode(&job) == nil {
+			mutex.Lock()
+			reported = append(reported, job)
+			mutex.Unlock()
+		}
+	}))
+	t.Cleanup(gateway.Close)
+
+	device := types.NewDevice("1", types.DeviceType__GENERIC_DUMMY, "Encoder")
+	device.AddAction(types.DeviceControl_REBOOT, reboot)
+	driver, err := NewDriver(&staticProvider{devices: []types.Device{device}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := NewService(gateway.URL, driver, log.New(io.Discard, "", 0))
+	return service, func() []types.Job {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]types.Job(nil), reported...)
+	}
+}
+
+func serveJob(t *testing.T, service *Service, method string, path string, body string) (int, types.Job) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	service.router.ServeHTTP(recorder, httptest.NewRequest(method, path, bytes.NewReader([]byte(body))))
+	var job types.Job
+	if recorder.Code < 300 {
+		if err := json.NewDecoder(recorder.Body).Decode(&job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return recorder.Code, job
+}
+
+func waitForJob(t *testing.T, service *Service, id types.JobId, done func(job types.Job) bool) types.Job {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		job, err := service.Job(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if done(job) {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout, job is %+v", job)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestJobs(t *testing.T) {
+	release := make(chan struct{})
+	service, reported := jobService(t, func(ctx context.Context, device types.Device) error {
+		types.ReportProgress(ctx, 0.5, "rebooting")
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	status, job := serveJob(t, service, http.MethodPost, "/1/REBOOT?async=true", "")
+	if status != http.StatusAccepted || job.Id == "" || job.Path.DeviceId != "1" || job.Control != "REBOOT" {
+		t.Fatalf("async control should be accepted as job, got %d %+v", status, job)
+	}
+	job = waitForJob(t, service, job.Id, func(job types.Job) bool { return job.Progress == 0.5 })
+	if job.State != types.JobState_RUNNING || job.Message != "rebooting" || job.Started == nil {
+		t.Errorf("job should be running with progress, got %+v", job)
+	}
+	close(release)
+	job = waitForJob(t, service, job.Id, func(job types.Job) bool { return job.State.Done() })
+	if job.State != types.JobState_SUCCEEDED || job.Progress != 1 || job.Finished == nil {
+		t.Errorf("job should have succeeded, got %+v", job)
+	}
+	if jobs := reported(); len(jobs) != 1 || jobs[0].Id != job.Id || jobs[0].State != types.JobState_SUCCEEDED {
+		t.Errorf("gateway should be told about the finished job, got %+v", jobs)
+	}
+
+	if status, _ := serveJob(t, service, http.MethodPost, "/2/REBOOT?async=true", ""); status != http.StatusNotFound {
+		t.Errorf("job for a missing device should be rejected, got %d", status)
+	}
+	if status, _ := serveJob(t, service, http.MethodGet, "/_jobs/unknown", ""); status != http.StatusNotFound {
+		t.Errorf("unknown job should not be found, got %d", status)
+	}
+	if status, _ := serveJob(t, service, http.MethodDelete, "/_jobs/"+string(job.Id), ""); status != http.StatusConflict {
+		t.Errorf("finished job should not be cancelled, got %d", status)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	service, reported := jobService(t, func(ctx context.Context, device types.Device) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	running := make([]types.Job, 0)
+	for i := 0; i < types.JOB_CONCURRENCY; i++ {
+		status, job := serveJob(t, service, http.MethodPost, "/_jobs", `{"path": {"deviceId": "1"}, "control": "REBOOT"}`)
+		if status != http.StatusAccepted {
+			t.Fatalf("expected 202, got %d", status)
+		}
+		running = append(running, waitForJob(t, service, job.Id, func(job types.Job) bool { return job.State == types.JobState_RUNNING }))
+	}
+	_, queued := serveJob(t, service, http.MethodPost, "/_jobs", `{"path": {"deviceId": "1"}, "control": "REBOOT"}`)
+	if queued.State != types.JobState_QUEUED {
+		t.Fatalf("job beyond the concurrency should be queued, got %+v", queued)
+	}
+	if status, job := serveJob(t, service, http.MethodDelete, "/_jobs/"+string(queued.Id), ""); status != http.StatusOK || job.State != types.JobState_CANCELLED {
+		t.Errorf("queued job should be cancelled right away, got %d %+v", status, job)
+	}
+
+	if _, err := service.CancelJob(running[0].Id); err != nil {
+		t.Fatal(err)
+	}
+	job := waitForJob(t, service, running[0].Id, func(job types.Job) bool { return job.State.Done() })
+	if job.State != types.JobState_CANCELLED {
+		t.Errorf("running job should be cancelled, got %+v", job)
+	}
+	if len(service.Jobs()) != types.JOB_CONCURRENCY+1 || len(reported()) != 2 {
+		t.Errorf("expected %d jobs and 2 reports, got %d and %d", types.JOB_CONCURRENCY+1, len(service.Jobs()), len(reported()))
+	}
+
+	_, timeout := serveJob(t, service, http.MethodPost, "/_jobs", `{"path": {"deviceId": "1"}, "control": "REBOOT", "timeoutMs": 1}`)
+	for _, job := range running[1:] {
+		service.CancelJob(job.Id)
+	}
+	job = waitForJob(t, service, timeout.Id, func(job types.Job) bool { return job.State.Done() })
+	if job.State != types.JobState_FAILED || job.Error == "" {
+		t.Errorf("job should fail on timeout, got %+v", job)
+	}
+}
+
+func TestShutdownJobs(t *testing.T) {
+	service, _ := jobService(t, func(ctx context.Context, device types.Device) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	jobs := make([]types.Job, 0)
+	for i := 0; i <= types.JOB_CONCURRENCY; i++ {
+		job, err := service.SubmitJob(types.JobRequest{Path: types.ElementPath{DeviceId: "1"}, Control: "REBOOT"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobs = append(jobs, job)
+	}
+	waitForJob(t, service, jobs[0].Id, func(job types.Job) bool { return job.State == types.JobState_RUNNING })
+
+	service.jobs.shutdown()
+	for _, job := range service.Jobs() {
+		if job.State != types.JobState_CANCELLED {
+			t.Errorf("queued and running jobs should be cancelled on shutdown, got %+v", job)
+		}
+	}
+	if _, err := service.SubmitJob(types.JobRequest{Path: types.ElementPath{DeviceId: "1"}, Control: "REBOOT"}); statusOf(err) != http.StatusServiceUnavailable {
+		t.Errorf("jobs should be rejected after shutdown, got %v", err)
+	}
+}
@@ -5,8 +5,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/lukirs95/monika-gosdk/pkg/types"
@@ -17,19 +23,41 @@ type Service struct {
 	driver           Driver
 	logger           *log.Logger
 	router           *mux.Router
+	notifier         *types.CoalescingNotifier
 	checkDeviceError types.ErrorCheckerDevice
 	checkModuleError types.ErrorCheckerModule
 	checkIOletError  types.ErrorCheckerIOlet
 	deviceErrors     map[types.DeviceId]*types.Error
-	moduleErrors     map[types.ModuleId]*types.Error
-	ioletErrors      map[types.IOletId]*types.Error
+	moduleErrors     map[moduleKey]*types.Error
+	ioletErrors      map[ioletKey]*types.Error
+	location         string
+	baseURL          string
+	checkGateway     func(gateway *types.GatewayInfo) error
+	gatewayInfo      atomic.Pointer[types.GatewayInfo]
+	// errorsMutex guards the reported errors. An error is only recorded once
+	// the gateway answered with its id, so checks hold it across requests.
+	errorsMutex sync.Mutex
+	// mutex guards the runners
+	mutex           sync.Mutex
+	runDevice       DeviceRunner
+	runners         map[types.Device]context.CancelFunc
+	runnerCtx       context.Context
+	refreshInterval time.Duration
+	jobs            *jobQueue
 }
 
+// DeviceRunner keeps a device in sync with the hardware until ctx is done,
+// e.g. by polling it. Devices keep their reference when their name or control
+// address changes on a refresh, so runners should read the control address
+// from the device on every connection.
+type DeviceRunner func(ctx context.Context, device types.Device)
+
 func NewService(gateway string, driver Driver, logger *log.Logger) *Service {
 	router := mux.NewRouter()
 
 	service := &Service{
 		router:           router,
+		notifier:         types.NewCoalescingNotifier(),
 		driver:           driver,
 		logger:           logger,
 		gateway:          gateway,
@@ -37,12 +65,24 @@ func NewService(gateway string, driver Driver, logger *log.Logger) *Service {
 		checkModuleError: func(device *types.ModuleUpdate) *types.Error { return nil },
 		checkIOletError:  func(device *types.IOletUpdate) *types.Error { return nil },
 		deviceErrors:     make(map[types.DeviceId]*types.Error),
-		moduleErrors:     make(map[types.ModuleId]*types.Error),
-		ioletErrors:      make(map[types.IOletId]*types.Error),
+		moduleErrors:     make(map[moduleKey]*types.Error),
+		ioletErrors:      make(map[ioletKey]*types.Error),
+		checkGateway:     func(gateway *types.GatewayInfo) error { return gateway.Compatible() },
+		runners:          make(map[types.Device]context.CancelFunc),
+		jobs:             newJobQueue(types.JOB_CONCURRENCY),
 	}
 
 	router.HandleFunc("/", service.handleGetDevices).Methods(http.MethodGet)
+	router.HandleFunc("/_bulk", service.handleBulkControl).Methods(http.MethodPost)
+	router.HandleFunc("/_jobs", service.handleGetJobs).Methods(http.MethodGet)
+	router.HandleFunc("/_jobs", service.handleSubmitJob).Methods(http.MethodPost)
+	router.HandleFunc("/_jobs/{jobId}", service.handleGetJob).Methods(http.MethodGet)
+	router.HandleFunc("/_jobs/{jobId}", service.handleCancelJob).Methods(http.MethodDelete)
 	router.HandleFunc("/{deviceId}", service.handleGetDevice).Methods(http.MethodGet)
+	router.HandleFunc("/{deviceId}/_tally", service.handleGetTally).Methods(http.MethodGet)
+	router.HandleFunc("/{deviceId}/_routes", service.handleGetRoutes).Methods(http.MethodGet)
+	router.HandleFunc("/{deviceId}/_routes", service.handleSetRoute).Methods(http.MethodPost)
+	router.HandleFunc("/{deviceId}/_routes/{moduleId}/{ioletId}", service.handleClearRoute).Methods(http.MethodDelete)
 	router.HandleFunc("/{deviceId}/{deviceControl}", service.handleDeviceControl).Methods(http.MethodPost)
 	router.HandleFunc("/{deviceId}/modules", service.handleGetModules).Methods(http.MethodGet)
 	router.HandleFunc("/{deviceId}/modules/{moduleType}", service.handleGetModulesByType).Methods(http.MethodGet)
@@ -51,27 +91,195 @@ func NewService(gateway string, driver Driver, logger *log.Logger) *Service {
 	router.HandleFunc("/{deviceId}/modules/{moduleType}/{moduleId}/iolets", service.handleGetIOlets).Methods(http.MethodGet)
 	router.HandleFunc("/{deviceId}/modules/{moduleType}/{moduleId}/iolets/{ioletType}", service.handleGetIOletsByType).Methods(http.MethodGet)
 	router.HandleFunc("/{deviceId}/modules/{moduleType}/{moduleId}/iolets/{ioletType}/{ioletId}", service.handleGetIOlet).Methods(http.MethodGet)
+	router.HandleFunc("/{deviceId}/modules/{moduleType}/{moduleId}/iolets/{ioletType}/{ioletId}/sdp", service.handleGetIOletSDP).Methods(http.MethodGet)
 	router.HandleFunc("/{deviceId}/modules/{moduleType}/{moduleId}/iolets/{ioletType}/{ioletId}/{ioletControl}", service.handleIOletControl).Methods(http.MethodPost)
+
+	for _, device := range driver.GetDevices() {
+		device.SetNotifier(service.notifier)
+	}
 	return service
 }
 
-func (service *Service) Listen(ctx context.Context, port int, updateChan chan types.Device) error {
+// Listen connects to the gateway and serves the driver api on port. Every
+// change in the device trees of the driver is reported to the gateway. When
+// ctx is done the server stops, requests in flight and device runners are
+// cancelled, and Listen returns ctx.Err() once the jobs are cancelled and
+// returned.
+func (service *Service) Listen(ctx context.Context, port int) error {
 	if err := service.connect(port); err != nil {
 		return err
 	}
 	defer service.disconnect()
+	// jobs end with the server, also if serving fails
+	defer service.jobs.shutdown()
+
+	server := &http.Server{
+		Addr:        fmt.Sprintf(":%d", port),
+		Handler:     service.router,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	closed := make(chan struct{})
+	stopShutdown := context.AfterFunc(ctx, func() {
+		defer close(closed)
+		server.Shutdown(context.Background())
+	})
+
+	service.startRunners(ctx)
+	go service.reportUpdates(ctx)
+	if service.refreshInterval > 0 {
+		go service.refreshDevices(ctx)
+	}
+
+	err := server.ListenAndServe()
+	if stopShutdown() {
+		return err
+	}
+	<-closed
+	return ctx.Err()
+}
 
-	go func(updateChan chan types.Device) {
-		for device := range updateChan {
-			updated := device.Updated()
-			if updated != nil {
-				service.checkForDeviceErrors(updated)
-				service.reportUpdate(updated)
+// reportUpdates reports changed devices until ctx is done. Changes happening
+// while a report is sent are coalesced into the next one.
+func (service *Service) reportUpdates(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-service.notifier.Signal():
+			current := make(map[types.Device]bool)
+			for _, device := range service.driver.GetDevices() {
+				current[device] = true
+			}
+			for _, device := range service.notifier.Take() {
+				// changes of a device retired meanwhile are not reported
+				if !current[device] {
+					continue
+				}
+				if updated := device.Updated(); updated != nil {
+					service.checkErrors(updated)
+					service.reportUpdate(updated)
+				}
 			}
 		}
-	}(updateChan)
+	}
+}
+
+// SetDeviceRunner sets the runner Listen starts for every device. Devices
+// added by a refresh get a runner of their own, the runners of retired devices
+// are cancelled.
+func (service *Service) SetDeviceRunner(runner DeviceRunner) {
+	service.runDevice = runner
+}
 
-	return http.ListenAndServe(fmt.Sprintf(":%d", port), service.router)
+// SetRefreshInterval makes Listen refresh the devices every interval, see
+// Refresh. Zero, the default, disables periodic refreshes.
+func (service *Service) SetRefreshInterval(interval time.Duration) {
+	service.refreshInterval = interval
+}
+
+// Refresh fetches the devices of the provider again, see Driver.Refresh, and
+// reports the added, retired and updated devices to the gateway.
+func (service *Service) Refresh(ctx context.Context) error {
+	changes, err := service.driver.Refresh(ctx)
+	if err != nil {
+		return err
+	}
+
+	service.mutex.Lock()
+	for _, device := range changes.Retired {
+		device.SetNotifier(nil)
+		service.stopRunner(device)
+	}
+	for _, device := range changes.Added {
+		device.SetNotifier(service.notifier)
+		service.startRunner(device)
+	}
+	service.mutex.Unlock()
+
+	for _, device := range changes.Retired {
+		service.errorsMutex.Lock()
+		service.clearDeviceErrors(device.GetId())
+		service.errorsMutex.Unlock()
+		service.reportUpdate(&types.DeviceUpdate{
+			Id:      device.GetId(),
+			Type:    device.GetType(),
+			Name:    device.GetName(),
+			Status:  device.GetStatus(),
+			Modules: make([]types.ModuleUpdate, 0),
+			Retired: true,
+		})
+	}
+	for _, device := range changes.Updated {
+		service.reportCompleteUpdate(device)
+	}
+	for _, device := range changes.Added {
+		service.reportCompleteUpdate(device)
+	}
+	return nil
+}
+
+// refreshDevices refreshes the devices every refreshInterval until ctx is
+// done.
+func (service *Service) refreshDevices(ctx context.Context) {
+	ticker := time.NewTicker(service.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := service.Refresh(ctx); err != nil {
+				service.logger.Print("could not refresh devices: ", err)
+			}
+		}
+	}
+}
+
+func (service *Service) reportCompleteUpdate(device types.Device) {
+	updated := types.CompleteDeviceUpdate(device)
+	service.checkErrors(updated)
+	service.reportUpdate(updated)
+}
+
+// checkErrors reports and deletes the errors of an update. Updates are sent
+// without holding a lock.
+func (service *Service) checkErrors(updated *types.DeviceUpdate) {
+	service.errorsMutex.Lock()
+	defer service.errorsMutex.Unlock()
+	service.checkForDeviceErrors(updated)
+}
+
+// startRunners starts the runners of all devices, which are cancelled with
+// ctx.
+func (service *Service) startRunners(ctx context.Context) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	service.runnerCtx = ctx
+	for _, device := range service.driver.GetDevices() {
+		service.startRunner(device)
+	}
+}
+
+// startRunner expects the caller to hold the lock. Before Listen started the
+// runners it does nothing.
+func (service *Service) startRunner(device types.Device) {
+	if service.runDevice == nil || service.runnerCtx == nil {
+		return
+	}
+	if _, ok := service.runners[device]; ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(service.runnerCtx)
+	service.runners[device] = cancel
+	go service.runDevice(ctx, device)
+}
+
+// stopRunner expects the caller to hold the lock.
+func (service *Service) stopRunner(device types.Device) {
+	if cancel, ok := service.runners[device]; ok {
+		cancel()
+		delete(service.runners, device)
+	}
 }
 
 func (service *Service) AddErrorCheckDevice(deviceChecker types.ErrorCheckerDevice) {
@@ -86,6 +294,110 @@ func (service *Service) AddErrorCheckIOlet(ioletChecker types.ErrorCheckerIOlet)
 	service.checkIOletError = ioletChecker
 }
 
+// SetLocation sets the location reported to the gateway, e.g. the rack the
+// driver runs in.
+func (service *Service) SetLocation(location string) {
+	service.location = location
+}
+
+// SetBaseURL sets the url the gateway reaches the driver api at, e.g. if the
+// driver runs behind a proxy.
+func (service *Service) SetBaseURL(baseURL string) {
+	service.baseURL = baseURL
+}
+
+// AddGatewayCheck replaces the check of the gateway's answer to connect.
+// Listen fails if check returns an error. The default check refuses gateways
+// which are not compatible, see types.GatewayInfo.Compatible, but accepts
+// gateways without handshake on purpose. Drivers which depend on the
+// handshake refuse them with a check of types.GatewayInfo.HasHandshake.
+func (service *Service) AddGatewayCheck(check func(gateway *types.GatewayInfo) error) {
+	service.checkGateway = check
+}
+
+// GatewayInfo returns the answer of the gateway to connect, nil before
+// Listen connected.
+func (service *Service) GatewayInfo() *types.GatewayInfo {
+	return service.gatewayInfo.Load()
+}
+
+// capabilities lists the features of the driver api served by Service which
+// the devices of the driver support. Updates are always streamed.
+func (service *Service) capabilities() []types.Capability {
+	supported := map[types.Capability]bool{types.Capability_STREAMING_UPDATES: true}
+	// control records a control of any element, which can run in bulk or
+	// as a job
+	control := func(parameters types.Parameters) {
+		supported[types.Capability_BULK_CONTROLS] = true
+		supported[types.Capability_JOBS] = true
+		if len(parameters) > 0 {
+			supported[types.Capability_PARAMETERIZED_CONTROLS] = true
+		}
+	}
+	for _, device := range service.driver.GetDevices() {
+		for _, name := range device.GetControls() {
+			control(device.GetParameters(name))
+		}
+		if device.CanRoute() {
+			supported[types.Capability_ROUTING] = true
+		}
+		if device.GetRedundancyGroup() != nil {
+			supported[types.Capability_REDUNDANCY] = true
+		}
+		for _, module := range device.GetModules() {
+			for _, name := range module.GetControls() {
+				control(module.GetParameters(name))
+			}
+			for _, iolet := range module.GetIOlets() {
+				for _, name := range iolet.GetControls() {
+					control(iolet.GetParameters(name))
+				}
+				if types.StreamOf(iolet) != nil {
+					supported[types.Capability_SDP] = true
+				}
+				if iolet.GetType().GPIO() {
+					supported[types.Capability_TALLY] = true
+				}
+			}
+		}
+	}
+
+	capabilities := make([]types.Capability, 0, len(supported))
+	for _, capability := range []types.Capability{
+		types.Capability_PARAMETERIZED_CONTROLS,
+		types.Capability_ROUTING,
+		types.Capability_STREAMING_UPDATES,
+		types.Capability_SDP,
+		types.Capability_TALLY,
+		types.Capability_BULK_CONTROLS,
+		types.Capability_JOBS,
+		types.Capability_REDUNDANCY,
+	} {
+		if supported[capability] {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	return capabilities
+}
+
+func buildInfo() *types.BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	build := &types.BuildInfo{
+		Module:    info.Main.Path,
+		Version:   info.Main.Version,
+		GoVersion: info.GoVersion,
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			build.Revision = setting.Value
+		}
+	}
+	return build
+}
+
 func (service *Service) reportUpdate(device *types.DeviceUpdate) {
 	body, err := json.Marshal(device)
 	if err != nil {
@@ -155,9 +467,21 @@ func (service *Service) deleteError(oldError *types.Error) error {
 }
 
 func (service *Service) connect(port int) error {
+	deviceType := service.driver.GetDeviceType()
+	deviceTypeDefinition := deviceType.Definition()
 	body, err := json.Marshal(&types.Driver{
-		DeviceType: service.driver.GetDeviceType(),
-		Port:       port,
+		DeviceType:           deviceType,
+		Port:                 port,
+		Location:             service.location,
+		BaseURL:              service.baseURL,
+		SDKVersion:           types.SDK_VERSION,
+		ProtocolVersion:      types.PROTOCOL_VERSION,
+		Build:                buildInfo(),
+		Capabilities:         service.capabilities(),
+		DeviceTypeDefinition: &deviceTypeDefinition,
+		DeviceControls:       types.DeviceControlDefinitions(),
+		ModuleControls:       types.ModuleControlDefinitions(),
+		IOletControls:        types.IOletControlDefinitions(),
 	})
 	if err != nil {
 		return err
@@ -169,9 +493,20 @@ func (service *Service) connect(port int) error {
 		return err
 	}
 
+	defer resp.Body.Close()
+
 	if resp.StatusCode != http.StatusCreated {
 		return fmt.Errorf("gateway responed with Status %s", resp.Status)
 	}
+
+	var gatewayInfo types.GatewayInfo
+	if err := json.NewDecoder(resp.Body).Decode(&gatewayInfo); err != nil && err != io.EOF {
+		return fmt.Errorf("could not read gateway info: %w", err)
+	}
+	if err := service.checkGateway(&gatewayInfo); err != nil {
+		return err
+	}
+	service.gatewayInfo.Store(&gatewayInfo)
 	return nil
 }
 
@@ -2,9 +2,24 @@ package driver
 
 import "github.com/lukirs95/monika-gosdk/pkg/types"
 
+// moduleKey identifies a module across all devices, module ids are only
+// unique within their device.
+type moduleKey struct {
+	deviceId types.DeviceId
+	moduleId types.ModuleId
+}
+
+// ioletKey identifies an iolet across all devices and modules.
+type ioletKey struct {
+	deviceId types.DeviceId
+	moduleId types.ModuleId
+	ioletId  types.IOletId
+}
+
+// checkForDeviceErrors expects the caller to hold the errors lock.
 func (service *Service) checkForDeviceErrors(device *types.DeviceUpdate) {
 	currentDeviceError, ok := service.deviceErrors[device.Id]
-	if deviceError := service.checkDeviceError(device); deviceError != nil { // new error
+	if deviceError := service.deviceError(device); deviceError != nil { // new error
 		if !ok { // no old error
 			service.reportDeviceError(device, deviceError)
 		} else { // there is an old error reported
@@ -24,48 +39,112 @@ func (service *Service) checkForDeviceErrors(device *types.DeviceUpdate) {
 	for _, module := range device.Modules {
 		service.checkForModuleErrors(device, &module)
 	}
+
+	for _, moduleId := range device.RemovedModules {
+		service.clearModuleErrors(moduleKey{device.Id, moduleId})
+	}
+}
+
+// deviceError runs the device error checker and raises "redundancy lost" on
+// devices without an error of their own.
+func (service *Service) deviceError(device *types.DeviceUpdate) *types.Error {
+	if deviceError := service.checkDeviceError(device); deviceError != nil {
+		return deviceError
+	}
+	return types.CheckRedundancy(device)
 }
 
 func (service *Service) checkForModuleErrors(device *types.DeviceUpdate, module *types.ModuleUpdate) {
-	currentModuleError, ok := service.moduleErrors[module.Id]
+	key := moduleKey{device.Id, module.Id}
+	currentModuleError, ok := service.moduleErrors[key]
 	if moduleError := service.checkModuleError(module); moduleError != nil { // new error
 		if !ok { // no old error
 			service.reportModuleError(device, module, moduleError)
 		} else { // there is an old error reported
 			if currentModuleError.Message != moduleError.Message { // same error
 				if moduleError.Severity > currentModuleError.Severity { // higher severity
-					service.deleteModuleError(module, currentModuleError)
+					service.deleteModuleError(key, currentModuleError)
 					service.reportModuleError(device, module, moduleError)
 				}
 			}
 		}
 	} else { // no new error
 		if ok { // there is an old error
-			service.deleteModuleError(module, currentModuleError)
+			service.deleteModuleError(key, currentModuleError)
+		}
+	}
+
+	if module.Replaced { // iolets not listed do not exist anymore
+		listed := make(map[types.IOletId]bool)
+		for _, iolet := range module.IOlets {
+			listed[iolet.Id] = true
+		}
+		for key, ioletError := range service.ioletErrors {
+			if key.deviceId == device.Id && key.moduleId == module.Id && !listed[key.ioletId] {
+				service.deleteIOletError(key, ioletError)
+			}
 		}
 	}
 
 	for _, iolet := range module.IOlets {
 		service.checkForIOletErrors(device, module, &iolet)
 	}
+
+	for _, ioletId := range module.RemovedIOlets {
+		key := ioletKey{device.Id, module.Id, ioletId}
+		if ioletError, ok := service.ioletErrors[key]; ok {
+			service.deleteIOletError(key, ioletError)
+		}
+	}
 }
 
 func (service *Service) checkForIOletErrors(device *types.DeviceUpdate, module *types.ModuleUpdate, iolet *types.IOletUpdate) {
-	currentIOletError, ok := service.ioletErrors[iolet.Id]
+	key := ioletKey{device.Id, module.Id, iolet.Id}
+	currentIOletError, ok := service.ioletErrors[key]
 	if ioletError := service.checkIOletError(iolet); ioletError != nil { // new error
 		if !ok { // no old error
 			service.reportIOletError(device, module, iolet, ioletError)
 		} else { // there is an old error reported
 			if currentIOletError.Message != ioletError.Message { // same error
 				if ioletError.Severity > currentIOletError.Severity { // higher severity
-					service.deleteIOletError(iolet, currentIOletError)
+					service.deleteIOletError(key, currentIOletError)
 					service.reportIOletError(device, module, iolet, ioletError)
 				}
 			}
 		}
 	} else { // no new error
 		if ok { // there is an old error
-			service.deleteIOletError(iolet, currentIOletError)
+			service.deleteIOletError(key, currentIOletError)
+		}
+	}
+}
+
+// clearDeviceErrors deletes the errors of a retired device, its modules and
+// iolets. It expects the caller to hold the errors lock.
+func (service *Service) clearDeviceErrors(deviceId types.DeviceId) {
+	if deviceError, ok := service.deviceErrors[deviceId]; ok {
+		service.deleteDeviceError(&types.DeviceUpdate{Id: deviceId}, deviceError)
+	}
+	for key := range service.moduleErrors {
+		if key.deviceId == deviceId {
+			service.clearModuleErrors(key)
+		}
+	}
+	for key, ioletError := range service.ioletErrors {
+		if key.deviceId == deviceId {
+			service.deleteIOletError(key, ioletError)
+		}
+	}
+}
+
+// clearModuleErrors deletes the errors of a removed module and its iolets.
+func (service *Service) clearModuleErrors(key moduleKey) {
+	if moduleError, ok := service.moduleErrors[key]; ok {
+		service.deleteModuleError(key, moduleError)
+	}
+	for iolet, ioletError := range service.ioletErrors {
+		if iolet.deviceId == key.deviceId && iolet.moduleId == key.moduleId {
+			service.deleteIOletError(iolet, ioletError)
 		}
 	}
 }
@@ -102,7 +181,7 @@ func (service *Service) reportModuleError(device *types.DeviceUpdate, module *ty
 		service.logger.Print(err)
 		return
 	}
-	service.moduleErrors[module.Id] = reportedError
+	service.moduleErrors[moduleKey{device.Id, module.Id}] = reportedError
 }
 
 func (service *Service) reportIOletError(device *types.DeviceUpdate, module *types.ModuleUpdate, iolet *types.IOletUpdate, ioletError *types.Error) {
@@ -124,7 +203,7 @@ func (service *Service) reportIOletError(device *types.DeviceUpdate, module *typ
 		service.logger.Print(err)
 		return
 	}
-	service.ioletErrors[iolet.Id] = reportedError
+	service.ioletErrors[ioletKey{device.Id, module.Id, iolet.Id}] = reportedError
 }
 
 func (service *Service) deleteDeviceError(device *types.DeviceUpdate, deviceError *types.Error) {
@@ -135,18 +214,18 @@ func (service *Service) deleteDeviceError(device *types.DeviceUpdate, deviceErro
 	delete(service.deviceErrors, device.Id)
 }
 
-func (service *Service) deleteModuleError(module *types.ModuleUpdate, moduleError *types.Error) {
+func (service *Service) deleteModuleError(key moduleKey, moduleError *types.Error) {
 	if err := service.deleteError(moduleError); err != nil {
 		service.logger.Print(err)
 		return
 	}
-	delete(service.moduleErrors, module.Id)
+	delete(service.moduleErrors, key)
 }
 
-func (service *Service) deleteIOletError(iolet *types.IOletUpdate, ioletError *types.Error) {
+func (service *Service) deleteIOletError(key ioletKey, ioletError *types.Error) {
 	if err := service.deleteError(ioletError); err != nil {
 		service.logger.Print(err)
 		return
 	}
-	delete(service.ioletErrors, iolet.Id)
+	delete(service.ioletErrors, key)
 }
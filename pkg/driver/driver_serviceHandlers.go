@@ -1,10 +1,14 @@
 package driver
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/lukirs95/monika-gosdk/pkg/types"
@@ -15,6 +19,66 @@ func logRequestError(logger *log.Logger, r *http.Request, err error) {
 	logger.Print(e)
 }
 
+// problem is the JSON body of an error response, see RFC 9457.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// statusOf maps the errors of the Driver interface, of jobs and timeouts of
+// controls to a status code. Other errors are blamed on the request.
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, types.ErrDeviceNotFound), errors.Is(err, types.ErrModuleNotFound), errors.Is(err, types.ErrIOletNotFound), errors.Is(err, types.ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, types.ErrTypeMismatch), errors.Is(err, types.ErrJobDone):
+		return http.StatusConflict
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, errShutdown):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+// writeProblem logs err and answers with a problem body.
+func (service *Service) writeProblem(w http.ResponseWriter, r *http.Request, status int, err error) {
+	logRequestError(service.logger, r, err)
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(&problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   err.Error(),
+		Instance: r.URL.Path,
+	}); err != nil {
+		logRequestError(service.logger, r, err)
+	}
+}
+
+// writeError answers with a problem body whose status depends on err, see
+// statusOf.
+func (service *Service) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	service.writeProblem(w, r, statusOf(err), err)
+}
+
+// decodeArguments reads the optional JSON object with the arguments of a
+// control from the request body.
+func decodeArguments(r *http.Request) (types.Arguments, error) {
+	var args types.Arguments
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+	return args, nil
+}
+
 func (service *Service) handleGetDevices(w http.ResponseWriter, r *http.Request) {
 	devices := service.driver.GetDevices()
 
@@ -29,7 +93,11 @@ func (service *Service) handleGetDevice(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	deviceId := types.DeviceId(vars["deviceId"])
 
-	device := service.driver.GetDevice(deviceId)
+	device, err := service.driver.GetDevice(deviceId)
+	if err != nil {
+		service.writeError(w, r, err)
+		return
+	}
 
 	w.Header().Add("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(device); err != nil {
@@ -37,20 +105,41 @@ func (service *Service) handleGetDevice(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// handleGetTally reports the levels of all GPIO iolets of a device.
+func (service *Service) handleGetTally(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	deviceId := types.DeviceId(vars["deviceId"])
+
+	device, err := service.driver.GetDevice(deviceId)
+	if err != nil {
+		service.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(types.DeviceTally(device)); err != nil {
+		logRequestError(service.logger, r, err)
+	}
+}
+
 func (service *Service) handleDeviceControl(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	deviceId := types.DeviceId(vars["deviceId"])
 	control := types.DeviceControl(vars["deviceControl"])
 
-	if err := control.Valid(); err != nil {
-		logRequestError(service.logger, r, err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	args, err := decodeArguments(r)
+	if err != nil {
+		service.writeError(w, r, err)
 		return
 	}
 
-	if err := service.driver.RunDeviceControl(r.Context(), deviceId, control); err != nil {
-		logRequestError(service.logger, r, err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if async(r) {
+		service.submitJob(w, r, types.JobRequest{Path: types.ElementPath{DeviceId: deviceId}, Control: string(control), Arguments: args})
+		return
+	}
+
+	if err := service.driver.RunDeviceControl(r.Context(), deviceId, control, args); err != nil {
+		service.writeError(w, r, err)
 		return
 	}
 }
@@ -59,7 +148,11 @@ func (service *Service) handleGetModules(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	deviceId := types.DeviceId(vars["deviceId"])
 
-	modules := service.driver.GetModules(deviceId)
+	modules, err := service.driver.GetModules(deviceId)
+	if err != nil {
+		service.writeError(w, r, err)
+		return
+	}
 
 	w.Header().Add("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(modules); err != nil {
@@ -72,7 +165,11 @@ func (service *Service) handleGetModulesByType(w http.ResponseWriter, r *http.Re
 	deviceId := types.DeviceId(vars["deviceId"])
 	moduleType := types.ModuleType(vars["moduleType"])
 
-	modules := service.driver.GetModulesByModuleType(deviceId, moduleType)
+	modules, err := service.driver.GetModulesByModuleType(deviceId, moduleType)
+	if err != nil {
+		service.writeError(w, r, err)
+		return
+	}
 
 	w.Header().Add("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(modules); err != nil {
@@ -86,7 +183,11 @@ func (service *Service) handleGetModule(w http.ResponseWriter, r *http.Request)
 	moduleType := types.ModuleType(vars["moduleType"])
 	moduleId := types.ModuleId(vars["moduleId"])
 
-	module := service.driver.GetModule(deviceId, moduleType, moduleId)
+	module, err := service.driver.GetModule(deviceId, moduleType, moduleId)
+	if err != nil {
+		service.writeError(w, r, err)
+		return
+	}
 
 	w.Header().Add("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(module); err != nil {
@@ -101,15 +202,20 @@ func (service *Service) handleModuleControl(w http.ResponseWriter, r *http.Reque
 	moduleId := types.ModuleId(vars["moduleId"])
 	control := types.ModuleControl(vars["moduleControl"])
 
-	if err := control.Valid(); err != nil {
-		logRequestError(service.logger, r, err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	args, err := decodeArguments(r)
+	if err != nil {
+		service.writeError(w, r, err)
 		return
 	}
 
-	if err := service.driver.RunModuleControl(r.Context(), deviceId, moduleType, moduleId, control); err != nil {
-		logRequestError(service.logger, r, err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if async(r) {
+		path := types.ElementPath{DeviceId: deviceId, ModuleType: moduleType, ModuleId: moduleId}
+		service.submitJob(w, r, types.JobRequest{Path: path, Control: string(control), Arguments: args})
+		return
+	}
+
+	if err := service.driver.RunModuleControl(r.Context(), deviceId, moduleType, moduleId, control, args); err != nil {
+		service.writeError(w, r, err)
 		return
 	}
 }
@@ -120,7 +226,11 @@ func (service *Service) handleGetIOlets(w http.ResponseWriter, r *http.Request)
 	moduleType := types.ModuleType(vars["moduleType"])
 	moduleId := types.ModuleId(vars["moduleId"])
 
-	iolets := service.driver.GetIOlets(deviceId, moduleType, moduleId)
+	iolets, err := service.driver.GetIOlets(deviceId, moduleType, moduleId)
+	if err != nil {
+		service.writeError(w, r, err)
+		return
+	}
 
 	w.Header().Add("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(iolets); err != nil {
@@ -135,7 +245,11 @@ func (service *Service) handleGetIOletsByType(w http.ResponseWriter, r *http.Req
 	moduleId := types.ModuleId(vars["moduleId"])
 	ioletType := types.IOletType(vars["ioletType"])
 
-	iolets := service.driver.GetIOletsByIOletType(deviceId, moduleType, moduleId, ioletType)
+	iolets, err := service.driver.GetIOletsByIOletType(deviceId, moduleType, moduleId, ioletType)
+	if err != nil {
+		service.writeError(w, r, err)
+		return
+	}
 
 	w.Header().Add("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(iolets); err != nil {
@@ -151,7 +265,11 @@ func (service *Service) handleGetIOlet(w http.ResponseWriter, r *http.Request) {
 	ioletType := types.IOletType(vars["ioletType"])
 	ioletId := types.IOletId(vars["ioletId"])
 
-	iolet := service.driver.GetIOlet(deviceId, moduleType, moduleId, ioletType, ioletId)
+	iolet, err := service.driver.GetIOlet(deviceId, moduleType, moduleId, ioletType, ioletId)
+	if err != nil {
+		service.writeError(w, r, err)
+		return
+	}
 
 	w.Header().Add("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(iolet); err != nil {
@@ -159,6 +277,31 @@ func (service *Service) handleGetIOlet(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (service *Service) handleGetIOletSDP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	deviceId := types.DeviceId(vars["deviceId"])
+	moduleType := types.ModuleType(vars["moduleType"])
+	moduleId := types.ModuleId(vars["moduleId"])
+	ioletType := types.IOletType(vars["ioletType"])
+	ioletId := types.IOletId(vars["ioletId"])
+
+	iolet, err := service.driver.GetIOlet(deviceId, moduleType, moduleId, ioletType, ioletId)
+	if err != nil {
+		service.writeError(w, r, err)
+		return
+	}
+	stream := types.StreamOf(iolet)
+	if stream == nil {
+		service.writeProblem(w, r, http.StatusNotFound, errors.New("iolet has no stream"))
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/sdp")
+	if _, err := io.WriteString(w, stream.SDP()); err != nil {
+		logRequestError(service.logger, r, err)
+	}
+}
+
 func (service *Service) handleIOletControl(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	deviceId := types.DeviceId(vars["deviceId"])
@@ -168,15 +311,173 @@ func (service *Service) handleIOletControl(w http.ResponseWriter, r *http.Reques
 	ioletId := types.IOletId(vars["ioletId"])
 	control := types.IOletControl(vars["ioletControl"])
 
-	if err := control.Valid(); err != nil {
+	args, err := decodeArguments(r)
+	if err != nil {
+		service.writeError(w, r, err)
+		return
+	}
+
+	if async(r) {
+		path := types.ElementPath{DeviceId: deviceId, ModuleType: moduleType, ModuleId: moduleId, IOletType: ioletType, IOletId: ioletId}
+		service.submitJob(w, r, types.JobRequest{Path: path, Control: string(control), Arguments: args})
+		return
+	}
+
+	if err := service.driver.RunIOletCommand(r.Context(), deviceId, moduleType, moduleId, ioletType, ioletId, control, args); err != nil {
+		service.writeError(w, r, err)
+		return
+	}
+}
+
+func (service *Service) handleGetRoutes(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	deviceId := types.DeviceId(vars["deviceId"])
+
+	routes, err := service.driver.GetRoutes(deviceId)
+	if err != nil {
+		service.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(routes); err != nil {
+		logRequestError(service.logger, r, err)
+	}
+}
+
+func (service *Service) handleSetRoute(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	deviceId := types.DeviceId(vars["deviceId"])
+
+	var route types.Route
+	if err := json.NewDecoder(r.Body).Decode(&route); err != nil {
+		service.writeError(w, r, err)
+		return
+	}
+	if route.Destination.DeviceId == "" {
+		route.Destination.DeviceId = deviceId
+	}
+	if route.Destination.DeviceId != deviceId {
+		err := fmt.Errorf("destination is not on device %s", deviceId)
+		service.writeError(w, r, err)
+		return
+	}
+
+	if err := service.driver.SetRoute(r.Context(), route); err != nil {
+		service.writeError(w, r, err)
+		return
+	}
+}
+
+func (service *Service) handleClearRoute(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	destination := types.IOletAddress{
+		DeviceId: types.DeviceId(vars["deviceId"]),
+		ModuleId: types.ModuleId(vars["moduleId"]),
+		IOletId:  types.IOletId(vars["ioletId"]),
+	}
+
+	if err := service.driver.ClearRoute(r.Context(), destination); err != nil {
+		service.writeError(w, r, err)
+		return
+	}
+}
+
+// handleBulkControl answers with the report of the bulk control, also if
+// controls of single elements failed.
+func (service *Service) handleBulkControl(w http.ResponseWriter, r *http.Request) {
+	var bulk types.BulkControl
+	if err := json.NewDecoder(r.Body).Decode(&bulk); err != nil {
+		service.writeError(w, r, fmt.Errorf("invalid bulk control: %w", err))
+		return
+	}
+
+	report, err := service.driver.RunBulkControl(r.Context(), bulk)
+	if err != nil {
+		service.writeError(w, r, err)
+		return
+	}
+	for i, result := range report.Results {
+		if result.Err != nil {
+			report.Results[i].Status = statusOf(result.Err)
+		} else {
+			report.Results[i].Status = http.StatusOK
+		}
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&report); err != nil {
 		logRequestError(service.logger, r, err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// async reports whether a control should run as a job, e.g.
+// POST /1/REBOOT?async=true.
+func async(r *http.Request) bool {
+	async, _ := strconv.ParseBool(r.URL.Query().Get("async"))
+	return async
+}
+
+// submitJob answers 202 with the job running request. The job is addressed
+// by its id, the path the gateway serves the driver api under is unknown here.
+func (service *Service) submitJob(w http.ResponseWriter, r *http.Request, request types.JobRequest) {
+	job, err := service.SubmitJob(request)
+	if err != nil {
+		service.writeError(w, r, err)
 		return
 	}
 
-	if err := service.driver.RunIOletCommand(r.Context(), deviceId, moduleType, moduleId, ioletType, ioletId, control); err != nil {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(&job); err != nil {
 		logRequestError(service.logger, r, err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+func (service *Service) handleSubmitJob(w http.ResponseWriter, r *http.Request) {
+	var request types.JobRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.writeError(w, r, fmt.Errorf("invalid job: %w", err))
 		return
 	}
+	service.submitJob(w, r, request)
+}
+
+func (service *Service) handleGetJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(service.Jobs()); err != nil {
+		logRequestError(service.logger, r, err)
+	}
+}
+
+func (service *Service) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	jobId := types.JobId(vars["jobId"])
+
+	job, err := service.Job(jobId)
+	if err != nil {
+		service.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&job); err != nil {
+		logRequestError(service.logger, r, err)
+	}
+}
+
+func (service *Service) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	jobId := types.JobId(vars["jobId"])
+
+	job, err := service.CancelJob(jobId)
+	if err != nil {
+		service.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&job); err != nil {
+		logRequestError(service.logger, r, err)
+	}
 }
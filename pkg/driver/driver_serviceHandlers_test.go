@@ -0,0 +1,78 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lukirs95/monika-gosdk/pkg/types"
+)
+
+func testService(t *testing.T) *Service {
+	device := types.NewDevice("1", types.DeviceType__GENERIC_DUMMY, "Encoder")
+	module := types.NewModule("1", types.ModuleType_AV, "Channel 1")
+	iolet := types.NewIOlet("1", types.IOletType_IPVIDEOIN, "Video In")
+	iolet.AddAction(types.IOletControl_START, func(ctx context.Context, iolet types.IOlet) error {
+		return nil
+	})
+	module.AddIOlet(iolet)
+	device.AddModule(module)
+
+	driver, err := NewDriver(&staticProvider{devices: []types.Device{device}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewService("http://127.0.0.1:0", driver, log.New(io.Discard, "", 0))
+}
+
+func TestHandlerProblems(t *testing.T) {
+	service := testService(t)
+	cases := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/1", http.StatusOK},
+		{http.MethodGet, "/2", http.StatusNotFound},
+		{http.MethodGet, "/1/_tally", http.StatusOK},
+		{http.MethodGet, "/2/_tally", http.StatusNotFound},
+		{http.MethodGet, "/1/modules/AV/1", http.StatusOK},
+		{http.MethodGet, "/1/modules/GPIO/1", http.StatusConflict},
+		{http.MethodGet, "/1/modules/AV/2/iolets", http.StatusNotFound},
+		{http.MethodGet, "/1/modules/AV/1/iolets/IP-VIDEO-IN/1", http.StatusOK},
+		{http.MethodGet, "/1/modules/AV/1/iolets/IP-VIDEO-OUT/1", http.StatusConflict},
+		{http.MethodGet, "/1/modules/AV/1/iolets/IP-VIDEO-IN/2", http.StatusNotFound},
+		{http.MethodGet, "/1/modules/AV/1/iolets/IP-VIDEO-IN/1/sdp", http.StatusNotFound},
+		{http.MethodPost, "/1/modules/AV/1/iolets/IP-VIDEO-IN/1/START", http.StatusOK},
+		{http.MethodPost, "/1/modules/AV/1/iolets/IP-VIDEO-IN/1/STOP", http.StatusBadRequest},
+		{http.MethodPost, "/1/modules/GPIO/1/iolets/IP-VIDEO-IN/1/START", http.StatusConflict},
+		{http.MethodPost, "/1/modules/AV/2/iolets/IP-VIDEO-IN/1/START", http.StatusNotFound},
+		{http.MethodPost, "/2/REBOOT", http.StatusNotFound},
+		{http.MethodGet, "/1/_routes", http.StatusOK},
+		{http.MethodGet, "/2/_routes", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		service.router.ServeHTTP(recorder, httptest.NewRequest(c.method, c.path, nil))
+		if recorder.Code != c.status {
+			t.Errorf("%s %s: expected %d, got %d %s", c.method, c.path, c.status, recorder.Code, recorder.Body)
+			continue
+		}
+		if c.status == http.StatusOK {
+			continue
+		}
+		var body problem
+		if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+			t.Errorf("%s %s: %v", c.method, c.path, err)
+			continue
+		}
+		if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/problem+json") || body.Status != c.status || body.Instance != c.path || body.Detail == "" {
+			t.Errorf("%s %s: unexpected problem %+v", c.method, c.path, body)
+		}
+	}
+}
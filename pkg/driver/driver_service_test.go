@@ -0,0 +1,167 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lukirs95/monika-gosdk/pkg/types"
+)
+
+func TestServiceRefresh(t *testing.T) {
+	var mutex sync.Mutex
+	updates := make([]types.DeviceUpdate, 0)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var update types.DeviceUpdate
+		if r.URL.Path == "/api/notify/update" && json.NewDecoder(r.Body).Decode(&update) == nil {
+			mutex.Lock()
+			updates = append(updates, update)
+			mutex.Unlock()
+		}
+	}))
+	defer gateway.Close()
+
+	retired := types.NewDevice("1", types.DeviceType__GENERIC_DUMMY, "Encoder")
+	provider := &staticProvider{devices: []types.Device{retired}}
+	driver, err := NewDriver(provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := NewService(gateway.URL, driver, log.New(io.Discard, "", 0))
+
+	running := make(chan types.Device, 2)
+	stopped := make(chan types.Device, 2)
+	service.SetDeviceRunner(func(ctx context.Context, device types.Device) {
+		running <- device
+		<-ctx.Done()
+		stopped <- device
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service.startRunners(ctx)
+	if device := receive(t, running); device != retired {
+		t.Fatal("runner should be started for every device")
+	}
+
+	module := types.NewModule("1", types.ModuleType_AV, "Channel 1")
+	module.AddIOlet(types.NewIOlet("1", types.IOletType_IPVIDEOIN, "Video In"))
+	added := types.NewDevice("2", types.DeviceType__GENERIC_DUMMY, "Decoder")
+	added.AddModule(module)
+	provider.devices = []types.Device{added}
+	if err := service.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if device := receive(t, stopped); device != retired {
+		t.Error("runner of retired device should be cancelled")
+	}
+	if device := receive(t, running); device != added {
+		t.Error("runner of added device should be started")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(updates) != 2 || updates[0].Id != "1" || !updates[0].Retired || updates[1].Id != "2" || len(updates[1].Modules) != 1 || len(updates[1].Modules[0].IOlets) != 1 {
+		t.Errorf("gateway should be told about the retired and the complete added device, got %+v", updates)
+	}
+}
+
+func TestListenShutdown(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/driver/connect" {
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer gateway.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	service := testService(t)
+	service.gateway = gateway.URL
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- service.Listen(ctx, port) }()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/", port)
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		res, err := http.Get(url)
+		if err == nil {
+			res.Body.Close()
+			break
+		}
+		if time.Since(start) > time.Second {
+			t.Fatal("driver api is not served: ", err)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Listen should return the context error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Listen should return when ctx is done")
+	}
+	if res, err := http.Get(url); err == nil {
+		res.Body.Close()
+		t.Error("driver api should not be served after Listen returned")
+	}
+	if _, err := service.SubmitJob(types.JobRequest{Path: types.ElementPath{DeviceId: "1"}, Control: "REBOOT"}); !errors.Is(err, errShutdown) {
+		t.Errorf("jobs should be refused after Listen returned, got %v", err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	service := testService(t)
+	if capabilities := service.capabilities(); !slices.Equal(capabilities, []types.Capability{
+		types.Capability_STREAMING_UPDATES,
+		types.Capability_BULK_CONTROLS,
+		types.Capability_JOBS,
+	}) {
+		t.Errorf("only controls should be advertised, got %v", capabilities)
+	}
+
+	device := service.driver.GetDevices()[0]
+	device.SetRouteAction(func(ctx context.Context, device types.Device, route types.Route) error {
+		return nil
+	})
+	module := device.GetModule("1")
+	module.GetIOlet("1").(types.Streamer).SetStream(&types.StreamDescriptor{SessionName: "Video"})
+	module.AddIOlet(types.NewIOlet("2", types.IOletType_IPGPIO, "Tally"))
+	if capabilities := service.capabilities(); !slices.Equal(capabilities, []types.Capability{
+		types.Capability_ROUTING,
+		types.Capability_STREAMING_UPDATES,
+		types.Capability_SDP,
+		types.Capability_TALLY,
+		types.Capability_BULK_CONTROLS,
+		types.Capability_JOBS,
+	}) {
+		t.Errorf("routing, sdp and tally should be advertised, got %v", capabilities)
+	}
+}
+
+func receive(t *testing.T, devices <-chan types.Device) types.Device {
+	t.Helper()
+	select {
+	case device := <-devices:
+		return device
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+		return nil
+	}
+}
@@ -0,0 +1,125 @@
+package nut
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// DEFAULT_PORT is the port upsd listens on.
+const DEFAULT_PORT = 3493
+
+// Client speaks the network protocol of the Network UPS Tools daemon upsd.
+// It is not safe for concurrent use.
+type Client struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+}
+
+// Dial connects to upsd at address, e.g. 192.168.1.20:3493.
+func Dial(ctx context.Context, address string) (*Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, reader: bufio.NewReader(conn), timeout: 5 * time.Second}, nil
+}
+
+// Login authenticates with upsd, which only some upsd configurations require
+// to read variables.
+func (client *Client) Login(ctx context.Context, username string, password string) error {
+	if _, err := client.command(ctx, "USERNAME "+quote(username)); err != nil {
+		return err
+	}
+	_, err := client.command(ctx, "PASSWORD "+quote(password))
+	return err
+}
+
+// ListVars returns all variables of ups, e.g. battery.charge.
+func (client *Client) ListVars(ctx context.Context, ups string) (map[string]string, error) {
+	header, err := client.command(ctx, "LIST VAR "+ups)
+	if err != nil {
+		return nil, err
+	}
+	if header != "BEGIN LIST VAR "+ups {
+		return nil, fmt.Errorf("upsd: unexpected response %q", header)
+	}
+
+	prefix := "VAR " + ups + " "
+	vars := make(map[string]string)
+	for {
+		line, err := client.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "END LIST VAR "+ups {
+			return vars, nil
+		}
+		name, value, found := strings.Cut(strings.TrimPrefix(line, prefix), " ")
+		if !strings.HasPrefix(line, prefix) || !found {
+			return nil, fmt.Errorf("upsd: unexpected response %q", line)
+		}
+		vars[name] = unquote(value)
+	}
+}
+
+// Close logs out and closes the connection.
+func (client *Client) Close() error {
+	client.conn.SetDeadline(time.Now().Add(client.timeout))
+	fmt.Fprint(client.conn, "LOGOUT\n")
+	return client.conn.Close()
+}
+
+// command sends a command and returns the first response line. ERR responses
+// are returned as error.
+func (client *Client) command(ctx context.Context, command string) (string, error) {
+	deadline := time.Now().Add(client.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := client.conn.SetDeadline(deadline); err != nil {
+		return "", err
+	}
+	if _, err := fmt.Fprintf(client.conn, "%s\n", command); err != nil {
+		return "", err
+	}
+	return client.readLine()
+}
+
+func (client *Client) readLine() (string, error) {
+	line, err := client.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if code, found := strings.CutPrefix(line, "ERR "); found {
+		return "", fmt.Errorf("upsd: %s", code)
+	}
+	return line, nil
+}
+
+func quote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
+
+func unquote(value string) string {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, `"`), `"`)
+	var builder strings.Builder
+	escaped := false
+	for _, r := range value {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
@@ -0,0 +1,193 @@
+package nut
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lukirs95/monika-gosdk/pkg/types"
+)
+
+// fakeUpsd answers LIST VAR for the UPS "ups" with vars and requires a
+// login if password is set.
+func fakeUpsd(t *testing.T, password string, vars func() map[string]string) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveUpsd(conn, password, vars())
+		}
+	}()
+	return listener
+}
+
+func serveUpsd(conn net.Conn, password string, vars map[string]string) {
+	defer conn.Close()
+	loggedIn := password == ""
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		command := scanner.Text()
+		switch {
+		case strings.HasPrefix(command, "USERNAME "):
+			fmt.Fprint(conn, "OK\n")
+		case strings.HasPrefix(command, "PASSWORD "):
+			loggedIn = command == "PASSWORD "+quote(password)
+			fmt.Fprint(conn, "OK\n")
+		case command == "LIST VAR ups":
+			if !loggedIn {
+				fmt.Fprint(conn, "ERR ACCESS-DENIED\n")
+				continue
+			}
+			fmt.Fprint(conn, "BEGIN LIST VAR ups\n")
+			for name, value := range vars {
+				fmt.Fprintf(conn, "VAR ups %s %s\n", name, quote(value))
+			}
+			fmt.Fprint(conn, "END LIST VAR ups\n")
+		case strings.HasPrefix(command, "LIST VAR "):
+			fmt.Fprint(conn, "ERR UNKNOWN-UPS\n")
+		case command == "LOGOUT":
+			fmt.Fprint(conn, "OK Goodbye\n")
+			return
+		default:
+			fmt.Fprint(conn, "ERR UNKNOWN-COMMAND\n")
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	upsd := fakeUpsd(t, "", func() map[string]string {
+		return map[string]string{"ups.status": "OL", "device.model": `Smart-UPS "1500"`}
+	})
+	defer upsd.Close()
+
+	client, err := Dial(context.Background(), upsd.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	vars, err := client.ListVars(context.Background(), "ups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["ups.status"] != "OL" || vars["device.model"] != `Smart-UPS "1500"` {
+		t.Errorf("unexpected vars %v", vars)
+	}
+	if _, err := client.ListVars(context.Background(), "other"); err == nil || !strings.Contains(err.Error(), "UNKNOWN-UPS") {
+		t.Errorf("expected UNKNOWN-UPS, got %v", err)
+	}
+}
+
+func TestUPS(t *testing.T) {
+	vars := map[string]string{
+		"battery.charge":  "100",
+		"battery.runtime": "1800",
+		"ups.load":        "23",
+		"input.voltage":   "230.4",
+		"output.voltage":  "229.8",
+		"ups.status":      "OL CHRG",
+	}
+	updates := make(chan map[string]string, 1)
+	upsd := fakeUpsd(t, "secret", func() map[string]string {
+		select {
+		case update := <-updates:
+			for name, value := range update {
+				vars[name] = value
+			}
+		default:
+		}
+		return vars
+	})
+	defer upsd.Close()
+
+	host, port, _ := net.SplitHostPort(upsd.Addr().String())
+	device := types.NewDevice("1", types.DeviceType_GENERIC_USV, "UPS Rack 1")
+	device.SetControlIP(host)
+	controlPort, _ := strconv.Atoi(port)
+	device.SetControlPort(controlPort)
+
+	ups := NewUPS(device, "ups")
+	if err := ups.Poll(context.Background()); err == nil {
+		t.Error("poll without login should fail")
+	}
+	ups.SetLogin("monika", "secret")
+	if err := ups.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	power := types.PowerStatusOf(ups.GetModule())
+	expected := types.PowerStatus{BatteryCharge: 100, BatteryRuntime: 30 * time.Minute, Load: 23, InputVoltage: 230.4, OutputVoltage: 229.8}
+	if power == nil || *power != expected {
+		t.Fatalf("expected %+v, got %+v", expected, power)
+	}
+	if !ups.GetModule().GetStatus().OK() || device.GetModule("ups").GetType() != types.ModuleType_POWER {
+		t.Error("UPS on line should be an OK power module")
+	}
+	device.Updated()
+
+	updates <- map[string]string{"ups.status": "OB DISCHRG LB", "battery.charge": "8", "battery.runtime": "90"}
+	if err := ups.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	update := device.Updated()
+	if update == nil || len(update.Modules) != 1 || update.Modules[0].Power == nil || update.Modules[0].Status.OK() {
+		t.Fatalf("low battery should be reported as NOK module, got %+v", update)
+	}
+	moduleError := CheckModuleError(&update.Modules[0])
+	if moduleError == nil || moduleError.Severity != types.PubErrorSeverity_HIGHEST || !strings.Contains(moduleError.Message, "1m30s") {
+		t.Errorf("unexpected error %+v", moduleError)
+	}
+
+	if err := ups.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if device.Updated() != nil {
+		t.Error("unchanged vars should not modify the device")
+	}
+
+	restarted := NewUPS(device, "ups")
+	if restarted.GetModule() != ups.GetModule() || len(device.GetModules()) != 1 {
+		t.Errorf("restarted UPS should reuse its module, got %d modules", len(device.GetModules()))
+	}
+}
+
+func TestCheckModuleError(t *testing.T) {
+	cases := map[string]types.PubErrorSeverity{
+		"OL":        -1,
+		"OL CHRG":   -1,
+		"OB":        types.PubErrorSeverity_MID,
+		"OL OVER":   types.PubErrorSeverity_MID,
+		"OL RB":     types.PubErrorSeverity_LOWEST,
+		"OB LB":     types.PubErrorSeverity_HIGHEST,
+		"OB LB RB":  types.PubErrorSeverity_HIGHEST,
+		"OB OVER":   types.PubErrorSeverity_MID,
+		"OL CHRG ?": -1,
+	}
+	for status, severity := range cases {
+		power := PowerStatusFromVars(map[string]string{"ups.status": status})
+		moduleError := CheckModuleError(&types.ModuleUpdate{Name: "ups", Power: &power})
+		if severity < 0 {
+			if moduleError != nil {
+				t.Errorf("%s should not be an error, got %+v", status, moduleError)
+			}
+			continue
+		}
+		if moduleError == nil || moduleError.Severity != severity {
+			t.Errorf("%s should be an error of severity %d, got %+v", status, severity, moduleError)
+		}
+	}
+	if CheckModuleError(&types.ModuleUpdate{Name: "av"}) != nil {
+		t.Error("modules without power status should not be an error")
+	}
+}
@@ -0,0 +1,160 @@
+package nut
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lukirs95/monika-gosdk/pkg/types"
+)
+
+// UPS keeps the POWER module of a device in sync with a UPS served by upsd on
+// the control ip of the device.
+type UPS struct {
+	device   types.Device
+	module   types.Module
+	power    types.PowerReporter
+	name     string
+	username string
+	password string
+}
+
+// NewUPS adds a POWER module for the UPS name of upsd to device, e.g. the
+// name "ups" of ups@192.168.1.20. A POWER module added by an earlier NewUPS,
+// e.g. before the runner of the device restarted, is reused.
+func NewUPS(device types.Device, name string) *UPS {
+	moduleId := types.ModuleId(name)
+	module := device.GetModule(moduleId)
+	power, ok := module.(types.PowerReporter)
+	if !ok || module.GetType() != types.ModuleType_POWER {
+		module = types.NewModule(moduleId, types.ModuleType_POWER, name)
+		power = module.(types.PowerReporter)
+		device.ReplaceModule(module)
+	}
+	return &UPS{device: device, module: module, power: power, name: name}
+}
+
+// SetLogin sets the credentials for upsd configurations requiring a login.
+func (ups *UPS) SetLogin(username string, password string) {
+	ups.username = username
+	ups.password = password
+}
+
+func (ups *UPS) GetModule() types.Module {
+	return ups.module
+}
+
+// Address returns the upsd address, the control port defaults to
+// DEFAULT_PORT.
+func (ups *UPS) Address() string {
+	port := ups.device.GetControlPort()
+	if port == 0 {
+		port = DEFAULT_PORT
+	}
+	return net.JoinHostPort(ups.device.GetControlIP(), strconv.Itoa(port))
+}
+
+// Poll reads the variables of the UPS once and applies them to the module.
+func (ups *UPS) Poll(ctx context.Context) error {
+	client, err := Dial(ctx, ups.Address())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ups.username != "" {
+		if err := client.Login(ctx, ups.username, ups.password); err != nil {
+			return err
+		}
+	}
+	vars, err := client.ListVars(ctx, ups.name)
+	if err != nil {
+		return err
+	}
+	ups.power.SetPowerStatus(PowerStatusFromVars(vars))
+	return nil
+}
+
+// Run polls the UPS every interval until ctx is done. The device is offline
+// while upsd can not be reached.
+func (ups *UPS) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := ups.Poll(ctx)
+		ups.device.ModifyStatus(func(status *types.DeviceStatus) {
+			status.SetONLINE(err == nil)
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PowerStatusFromVars maps the standard NUT variables to a PowerStatus.
+func PowerStatusFromVars(vars map[string]string) types.PowerStatus {
+	number := func(name string) float64 {
+		value, _ := strconv.ParseFloat(vars[name], 64)
+		return value
+	}
+
+	status := types.PowerStatus{
+		BatteryCharge:  number("battery.charge"),
+		BatteryRuntime: time.Duration(number("battery.runtime")) * time.Second,
+		Load:           number("ups.load"),
+		InputVoltage:   number("input.voltage"),
+		OutputVoltage:  number("output.voltage"),
+	}
+	// ups.status lists flags like "OL CHRG" or "OB LB"
+	for _, flag := range strings.Fields(vars["ups.status"]) {
+		switch flag {
+		case "OB":
+			status.OnBattery = true
+		case "LB":
+			status.LowBattery = true
+		case "RB":
+			status.ReplaceBattery = true
+		case "OVER":
+			status.Overload = true
+		}
+	}
+	return status
+}
+
+// CheckModuleError is an error checker for POWER modules, see
+// driver.Service.AddErrorCheckModule. Modules without power status are OK.
+func CheckModuleError(module *types.ModuleUpdate) *types.Error {
+	power := module.Power
+	if power == nil {
+		return nil
+	}
+	switch {
+	case power.LowBattery:
+		return &types.Error{
+			Severity: types.PubErrorSeverity_HIGHEST,
+			Message:  fmt.Sprintf("UPS %s battery low, %.0f%% charge, %s runtime left", module.Name, power.BatteryCharge, power.BatteryRuntime),
+		}
+	case power.OnBattery:
+		return &types.Error{
+			Severity: types.PubErrorSeverity_MID,
+			Message:  fmt.Sprintf("UPS %s on battery", module.Name),
+		}
+	case power.Overload:
+		return &types.Error{
+			Severity: types.PubErrorSeverity_MID,
+			Message:  fmt.Sprintf("UPS %s overloaded, %.0f%% load", module.Name, power.Load),
+		}
+	case power.ReplaceBattery:
+		return &types.Error{
+			Severity: types.PubErrorSeverity_LOWEST,
+			Message:  fmt.Sprintf("UPS %s battery needs replacement", module.Name),
+		}
+	}
+	return nil
+}
@@ -5,11 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/lukirs95/monika-gosdk/pkg/types"
 )
 
 type Netbox struct {
+	mutex        sync.RWMutex
 	server       string
 	apiKey       string
 	deviceTypeID int
@@ -50,26 +52,47 @@ func (netbox *Netbox) FetchDevices(ctx context.Context) error {
 		allResponses = append(allResponses, resDevices...)
 	}
 
+	netbox.mutex.Lock()
+	defer netbox.mutex.Unlock()
+
+	// known devices keep their reference, devices not included anymore are
+	// dropped
+	known := make(map[types.DeviceId]types.Device)
+	for _, device := range netbox.devices {
+		known[device.GetId()] = device
+	}
+	devices := make([]types.Device, 0, len(allResponses))
 	for _, resDevice := range allResponses {
-		if resDevice.Include() && resDevice.GetIP() != "" {
-			newDevice := types.NewDevice(resDevice.GetId(), netbox.deviceType, resDevice.Name)
-			newDevice.SetControlIP(resDevice.GetIP())
-			netbox.devices = append(netbox.devices, newDevice)
+		if !resDevice.Include() || resDevice.GetIP() == "" {
+			continue
+		}
+		device, ok := known[resDevice.GetId()]
+		if !ok {
+			device = types.NewDevice(resDevice.GetId(), netbox.deviceType, resDevice.Name)
 		}
+		delete(known, resDevice.GetId())
+		device.SetName(resDevice.Name)
+		device.SetControlIP(resDevice.GetIP())
+		devices = append(devices, device)
 	}
+	netbox.devices = devices
 
 	return nil
 }
 
 func (netbox *Netbox) GetDevices() []types.Device {
-	return netbox.devices
+	netbox.mutex.RLock()
+	defer netbox.mutex.RUnlock()
+	devices := make([]types.Device, len(netbox.devices))
+	copy(devices, netbox.devices)
+	return devices
 }
 
 func (netbox *Netbox) request(ctx context.Context, endpoint string) ([]json.RawMessage, error) {
 	next := endpoint
 	responses := make([]json.RawMessage, 0)
 	for next != "" {
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, next, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -82,8 +105,15 @@ func (netbox *Netbox) request(ctx context.Context, endpoint string) ([]json.RawM
 			return nil, err
 		}
 
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			return nil, fmt.Errorf("netbox %s responded %s", next, res.Status)
+		}
+
 		var response Response
-		if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		err = json.NewDecoder(res.Body).Decode(&response)
+		res.Body.Close()
+		if err != nil {
 			return nil, err
 		}
 		responses = append(responses, response.Results)
@@ -0,0 +1,94 @@
+package nmos
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lukirs95/monika-gosdk/pkg/types"
+)
+
+// enableSender returns the START or STOP action of a sender. A started sender
+// reports its new transport file as stream.
+func enableSender(connection *Connection, enable bool) types.IOletAction {
+	return func(ctx context.Context, iolet types.IOlet) error {
+		senderId := string(iolet.GetId())
+		if err := connection.EnableSender(ctx, senderId, enable); err != nil {
+			return err
+		}
+		iolet.ModifyStatus(func(status *types.IOletStatus) {
+			status.SetRunning(enable)
+			status.SetSending(enable)
+		})
+		if !enable {
+			return nil
+		}
+		streamer, ok := iolet.(types.Streamer)
+		if !ok {
+			return nil
+		}
+		if sdp, err := connection.TransportFile(ctx, senderId); err == nil {
+			if stream, err := types.ParseSDP(sdp); err == nil {
+				streamer.SetStream(stream)
+			}
+		}
+		return nil
+	}
+}
+
+// enableReceiver returns the START or STOP action of a receiver, which keeps
+// its sender.
+func enableReceiver(connection *Connection, enable bool) types.IOletAction {
+	return func(ctx context.Context, iolet types.IOlet) error {
+		if err := connection.EnableReceiver(ctx, string(iolet.GetId()), enable); err != nil {
+			return err
+		}
+		iolet.ModifyStatus(func(status *types.IOletStatus) {
+			status.SetRunning(enable)
+		})
+		return nil
+	}
+}
+
+// routeAction connects the receiver of the route destination to the sender
+// of its source, using the manifest the sender registered.
+func (nmos *Nmos) routeAction(connection *Connection) types.RouteAction {
+	return func(ctx context.Context, device types.Device, route types.Route) error {
+		receiverId := string(route.Destination.IOletId)
+		if !route.Connected() {
+			if err := connection.DisconnectReceiver(ctx, receiverId); err != nil {
+				return err
+			}
+			device.ClearRoute(route.Destination)
+			setReceiverRunning(device, route.Destination, false)
+			return nil
+		}
+
+		senderId := string(route.Source.IOletId)
+		href := nmos.manifestHref(senderId)
+		if href == "" {
+			return fmt.Errorf("sender %s has no manifest", route.Source)
+		}
+		sdp, err := get(ctx, href)
+		if err != nil {
+			return err
+		}
+		if err := connection.ConnectReceiver(ctx, receiverId, senderId, sdp); err != nil {
+			return err
+		}
+		device.SetRoute(route)
+		setReceiverRunning(device, route.Destination, true)
+		return nil
+	}
+}
+
+func setReceiverRunning(device types.Device, address types.IOletAddress, running bool) {
+	module := device.GetModule(address.ModuleId)
+	if module == nil {
+		return
+	}
+	if iolet := module.GetIOlet(address.IOletId); iolet != nil {
+		iolet.ModifyStatus(func(status *types.IOletStatus) {
+			status.SetRunning(running)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package nmos
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const ACTIVATION_IMMEDIATE = "activate_immediate"
+
+// Connection is a client of the IS-05 Connection API of one device. Every
+// change is staged and activated immediately.
+type Connection struct {
+	connectionAPI string
+}
+
+// NewConnection returns a client for the Connection API at connectionAPI,
+// e.g. http://192.168.1.10/x-nmos/connection/v1.1.
+func NewConnection(connectionAPI string) *Connection {
+	return &Connection{connectionAPI: strings.TrimSuffix(connectionAPI, "/")}
+}
+
+type Activation struct {
+	Mode string `json:"mode"`
+}
+
+type TransportFile struct {
+	Data *string `json:"data"`
+	Type *string `json:"type"`
+}
+
+// StagedSender is the body of a staged sender PATCH. Nil fields are left
+// unchanged.
+type StagedSender struct {
+	ReceiverId   *string    `json:"receiver_id,omitempty"`
+	MasterEnable *bool      `json:"master_enable,omitempty"`
+	Activation   Activation `json:"activation"`
+}
+
+// StagedReceiver is the body of a staged receiver PATCH. SenderId is always
+// sent, nil disconnects the receiver.
+type StagedReceiver struct {
+	SenderId      *string        `json:"sender_id"`
+	MasterEnable  *bool          `json:"master_enable,omitempty"`
+	TransportFile *TransportFile `json:"transport_file,omitempty"`
+	Activation    Activation     `json:"activation"`
+}
+
+// EnableSender starts or stops a sender.
+func (connection *Connection) EnableSender(ctx context.Context, senderId string, enable bool) error {
+	staged := StagedSender{MasterEnable: &enable, Activation: Activation{Mode: ACTIVATION_IMMEDIATE}}
+	return connection.patch(ctx, fmt.Sprintf("single/senders/%s/staged", senderId), staged)
+}
+
+// EnableReceiver starts or stops a receiver without changing its sender.
+func (connection *Connection) EnableReceiver(ctx context.Context, receiverId string, enable bool) error {
+	staged := struct {
+		MasterEnable bool       `json:"master_enable"`
+		Activation   Activation `json:"activation"`
+	}{MasterEnable: enable, Activation: Activation{Mode: ACTIVATION_IMMEDIATE}}
+	return connection.patch(ctx, fmt.Sprintf("single/receivers/%s/staged", receiverId), staged)
+}
+
+// ConnectReceiver subscribes a receiver to a sender described by sdp and
+// enables it.
+func (connection *Connection) ConnectReceiver(ctx context.Context, receiverId string, senderId string, sdp string) error {
+	enable := true
+	sdpType := "application/sdp"
+	staged := StagedReceiver{
+		SenderId:      &senderId,
+		MasterEnable:  &enable,
+		TransportFile: &TransportFile{Data: &sdp, Type: &sdpType},
+		Activation:    Activation{Mode: ACTIVATION_IMMEDIATE},
+	}
+	return connection.patch(ctx, fmt.Sprintf("single/receivers/%s/staged", receiverId), staged)
+}
+
+// DisconnectReceiver unsubscribes and disables a receiver.
+func (connection *Connection) DisconnectReceiver(ctx context.Context, receiverId string) error {
+	enable := false
+	staged := StagedReceiver{
+		MasterEnable:  &enable,
+		TransportFile: &TransportFile{},
+		Activation:    Activation{Mode: ACTIVATION_IMMEDIATE},
+	}
+	return connection.patch(ctx, fmt.Sprintf("single/receivers/%s/staged", receiverId), staged)
+}
+
+// TransportFile returns the active SDP of a sender.
+func (connection *Connection) TransportFile(ctx context.Context, senderId string) (string, error) {
+	return get(ctx, fmt.Sprintf("%s/single/senders/%s/transportfile", connection.connectionAPI, senderId))
+}
+
+func (connection *Connection) patch(ctx context.Context, path string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/%s", connection.connectionAPI, path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusAccepted {
+		return connectionError(endpoint, res)
+	}
+	return nil
+}
+
+// get fetches a text resource, e.g. a transport file or manifest.
+func get(ctx context.Context, endpoint string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/sdp")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", connectionError(endpoint, res)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// connectionError reports the error body of an NMOS API, which carries the
+// reason in its error field.
+func connectionError(endpoint string, res *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+		Debug string `json:"debug"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err == nil && body.Error != "" {
+		return fmt.Errorf("%s responded %s: %s", endpoint, res.Status, body.Error)
+	}
+	return fmt.Errorf("%s responded %s", endpoint, res.Status)
+}
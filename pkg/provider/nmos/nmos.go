@@ -0,0 +1,381 @@
+package nmos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/lukirs95/monika-gosdk/pkg/types"
+)
+
+// Nmos discovers devices from the Query API of an AMWA NMOS IS-04 registry.
+// Senders and receivers become IP iolets, grouped into modules by their
+// grouphint tag or, without one, by their format. Devices with an IS-05
+// Connection API get iolet and route actions which connect them.
+type Nmos struct {
+	mutex      sync.RWMutex
+	queryAPI   string
+	query      url.Values
+	deviceType types.DeviceType
+	devices    []types.Device
+	// manifests maps sender ids to their manifest href
+	manifests map[string]string
+}
+
+// NewNmos returns a provider for the Query API at queryAPI, e.g.
+// http://registry/x-nmos/query/v1.3. query filters the NMOS devices using
+// basic queries, e.g. label=Camera 1, nil selects all devices.
+func NewNmos(queryAPI string, deviceType types.DeviceType, query url.Values) *Nmos {
+	return &Nmos{
+		queryAPI:   strings.TrimSuffix(queryAPI, "/"),
+		query:      query,
+		deviceType: deviceType,
+		devices:    make([]types.Device, 0),
+		manifests:  make(map[string]string),
+	}
+}
+
+func (nmos *Nmos) GetDeviceType() types.DeviceType {
+	return nmos.deviceType
+}
+
+func (nmos *Nmos) GetDevices() []types.Device {
+	nmos.mutex.RLock()
+	defer nmos.mutex.RUnlock()
+	devices := make([]types.Device, len(nmos.devices))
+	copy(devices, nmos.devices)
+	return devices
+}
+
+func (nmos *Nmos) FetchDevices(ctx context.Context) error {
+	resDevices, err := fetch[Device](ctx, nmos, "devices", nmos.query)
+	if err != nil {
+		return err
+	}
+	nodes, err := fetch[Node](ctx, nmos, "nodes", nil)
+	if err != nil {
+		return err
+	}
+	flows, err := fetch[Flow](ctx, nmos, "flows", nil)
+	if err != nil {
+		return err
+	}
+	senders, err := fetch[Sender](ctx, nmos, "senders", nil)
+	if err != nil {
+		return err
+	}
+	receivers, err := fetch[Receiver](ctx, nmos, "receivers", nil)
+	if err != nil {
+		return err
+	}
+
+	nodesById := make(map[string]Node)
+	for _, node := range nodes {
+		nodesById[node.Id] = node
+	}
+	formats := make(map[string]string)
+	for _, flow := range flows {
+		formats[flow.Id] = flow.Format
+	}
+
+	// addresses of all senders, so receivers can be routed to senders of
+	// other devices
+	registry := &resources{
+		senders:         senders,
+		receivers:       receivers,
+		formats:         formats,
+		senderAddresses: make(map[string]types.IOletAddress),
+	}
+	nmos.mutex.Lock()
+	for _, sender := range senders {
+		nmos.manifests[sender.Id] = sender.ManifestHref
+	}
+	nmos.mutex.Unlock()
+	for _, sender := range senders {
+		registry.senderAddresses[sender.Id] = types.IOletAddress{
+			DeviceId: types.DeviceId(sender.DeviceId),
+			ModuleId: moduleId(sender.Tags, formats[sender.FlowId]),
+			IOletId:  types.IOletId(sender.Id),
+		}
+	}
+
+	// known devices keep their reference and are reconciled in place,
+	// devices the registry expired are dropped
+	known := make(map[types.DeviceId]types.Device)
+	for _, device := range nmos.GetDevices() {
+		known[device.GetId()] = device
+	}
+	devices := make([]types.Device, 0, len(resDevices))
+	for _, resDevice := range resDevices {
+		controlIP, controlPort := "", 0
+		if node, ok := nodesById[resDevice.NodeId]; ok {
+			if endpoint, ok := node.endpoint(); ok {
+				controlIP, controlPort = endpoint.Host, endpoint.Port
+			}
+		}
+		device, ok := known[resDevice.GetId()]
+		if ok {
+			delete(known, resDevice.GetId())
+			device.SetName(resDevice.Name())
+		} else {
+			device = types.NewDevice(resDevice.GetId(), nmos.deviceType, resDevice.Name())
+			device.ModifyStatus(func(status *types.DeviceStatus) {
+				// registered nodes send heartbeats, otherwise the registry
+				// would have expired them
+				status.SetONLINE(true)
+			})
+		}
+		device.SetControlIP(controlIP)
+		device.SetControlPort(controlPort)
+
+		var connection *Connection
+		var routeAction types.RouteAction
+		if connectionAPI := resDevice.ConnectionAPI(); connectionAPI != "" {
+			connection = NewConnection(connectionAPI)
+			routeAction = nmos.routeAction(connection)
+		}
+		device.SetRouteAction(routeAction)
+
+		reconcile(ctx, device, connection, registry)
+		devices = append(devices, device)
+	}
+
+	nmos.mutex.Lock()
+	nmos.devices = devices
+	nmos.mutex.Unlock()
+
+	return nil
+}
+
+// resources are the senders and receivers of all devices read by one fetch.
+type resources struct {
+	senders         []Sender
+	receivers       []Receiver
+	formats         map[string]string
+	senderAddresses map[string]types.IOletAddress
+}
+
+// reconcile brings the modules, iolets and routes of device in line with its
+// senders and receivers. Known iolets keep their reference, new ones are
+// added and vanished ones removed, as are modules left empty. The actions of
+// all iolets are bound to the current connection, which may have moved.
+func reconcile(ctx context.Context, device types.Device, connection *Connection, registry *resources) {
+	deviceId := device.GetId()
+	module := func(id types.ModuleId) types.Module {
+		if module := device.GetModule(id); module != nil {
+			return module
+		}
+		module := types.NewModule(id, types.ModuleType_AV, string(id))
+		device.ReplaceModule(module)
+		return module
+	}
+	// iolet returns the known iolet at address, or a new one if there is
+	// none or it changed its type. New iolets get added with ReplaceIOlet
+	// once set up, which reports them completely.
+	iolet := func(address types.IOletAddress, ioletType types.IOletType, name string) (types.IOlet, bool) {
+		if known := device.GetModule(address.ModuleId); known != nil {
+			if iolet := known.GetIOlet(address.IOletId); iolet != nil && iolet.GetType() == ioletType {
+				iolet.SetName(name)
+				return iolet, false
+			}
+		}
+		return types.NewIOlet(address.IOletId, ioletType, name), true
+	}
+	found := make(map[types.IOletAddress]bool)
+
+	for _, sender := range registry.senders {
+		if types.DeviceId(sender.DeviceId) != deviceId {
+			continue
+		}
+		address := registry.senderAddresses[sender.Id]
+		found[address] = true
+		senderIOlet, added := iolet(address, ioletType(registry.formats[sender.FlowId], true), sender.Name())
+		senderIOlet.ModifyStatus(func(status *types.IOletStatus) {
+			status.SetRunning(sender.Subscription.Active)
+			status.SetSending(sender.Subscription.Active)
+		})
+		if streamer, ok := senderIOlet.(types.Streamer); ok {
+			if stream := manifest(ctx, sender.ManifestHref); stream != nil {
+				streamer.SetStream(stream)
+			}
+		}
+		bindActions(senderIOlet, connection, enableSender)
+		if added {
+			module(address.ModuleId).ReplaceIOlet(senderIOlet)
+		}
+	}
+
+	for _, receiver := range registry.receivers {
+		if types.DeviceId(receiver.DeviceId) != deviceId {
+			continue
+		}
+		address := types.IOletAddress{
+			DeviceId: deviceId,
+			ModuleId: moduleId(receiver.Tags, receiver.Format),
+			IOletId:  types.IOletId(receiver.Id),
+		}
+		found[address] = true
+		receiverIOlet, added := iolet(address, ioletType(receiver.Format, false), receiver.Name())
+		receiverIOlet.ModifyStatus(func(status *types.IOletStatus) {
+			status.SetRunning(receiver.Subscription.Active)
+		})
+		bindActions(receiverIOlet, connection, enableReceiver)
+		if added {
+			module(address.ModuleId).ReplaceIOlet(receiverIOlet)
+		}
+
+		source, ok := registry.senderAddresses[receiver.Subscription.SenderId]
+		if receiver.Subscription.Active && ok {
+			device.SetRoute(types.Route{Source: source, Destination: address})
+		} else {
+			device.ClearRoute(address)
+		}
+	}
+
+	for _, known := range device.GetModules() {
+		for _, knownIOlet := range known.GetIOlets() {
+			address := types.IOletAddress{DeviceId: deviceId, ModuleId: known.GetId(), IOletId: knownIOlet.GetId()}
+			if !found[address] {
+				known.RemoveIOlet(knownIOlet.GetId())
+			}
+		}
+		if len(known.GetIOlets()) == 0 {
+			device.RemoveModule(known.GetId())
+		}
+	}
+	for _, route := range device.GetRoutes() {
+		if !found[route.Destination] {
+			device.ClearRoute(route.Destination)
+		}
+	}
+}
+
+// bindActions registers the START and STOP actions of iolet with connection,
+// replacing those of an earlier connection, or removes them if the device
+// has no Connection API anymore.
+func bindActions(iolet types.IOlet, connection *Connection, enable func(connection *Connection, enable bool) types.IOletAction) {
+	if connection == nil {
+		if remover, ok := iolet.(types.IOletActionRemover); ok {
+			remover.RemoveAction(types.IOletControl_START)
+			remover.RemoveAction(types.IOletControl_STOP)
+		}
+		return
+	}
+	iolet.AddAction(types.IOletControl_START, enable(connection, true))
+	iolet.AddAction(types.IOletControl_STOP, enable(connection, false))
+}
+
+func moduleId(tags map[string][]string, format string) types.ModuleId {
+	if name := group(tags); name != "" {
+		return types.ModuleId(name)
+	}
+	return types.ModuleId(formatName(format))
+}
+
+// manifest fetches the SDP of a sender. Senders without a reachable or valid
+// manifest are still discovered, just without stream.
+func manifest(ctx context.Context, href string) *types.StreamDescriptor {
+	if href == "" {
+		return nil
+	}
+	sdp, err := get(ctx, href)
+	if err != nil {
+		return nil
+	}
+	stream, err := types.ParseSDP(sdp)
+	if err != nil {
+		return nil
+	}
+	return stream
+}
+
+func (nmos *Nmos) manifestHref(senderId string) string {
+	nmos.mutex.RLock()
+	defer nmos.mutex.RUnlock()
+	return nmos.manifests[senderId]
+}
+
+func fetch[T any](ctx context.Context, nmos *Nmos, resource string, query url.Values) ([]T, error) {
+	// without paging parameters a registry returns the most recent page,
+	// paging from the start walks all resources in next direction
+	paged := url.Values{"paging.since": {"0:0"}}
+	for key, values := range query {
+		paged[key] = values
+	}
+	endpoint := fmt.Sprintf("%s/%s?%s", nmos.queryAPI, resource, paged.Encode())
+
+	responses, err := nmos.request(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]T, 0)
+	for _, response := range responses {
+		var resource T
+		if err := json.Unmarshal(response, &resource); err != nil {
+			return nil, err
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+// request follows the paging links of the Query API and returns the resources
+// of all pages. The registry links a next page even from the last one, which
+// is empty.
+func (nmos *Nmos) request(ctx context.Context, endpoint string) ([]json.RawMessage, error) {
+	next := endpoint
+	responses := make([]json.RawMessage, 0)
+	for next != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, next, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Accept", "application/json")
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			return nil, fmt.Errorf("query api %s responded %s", next, res.Status)
+		}
+
+		var page []json.RawMessage
+		err = json.NewDecoder(res.Body).Decode(&page)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			break
+		}
+		responses = append(responses, page...)
+		next = nextLink(res.Header.Get("Link"))
+	}
+
+	return responses, nil
+}
+
+// nextLink returns the target of rel="next" in a Link header.
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		target, params, found := strings.Cut(link, ";")
+		if !found {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			if strings.ReplaceAll(strings.TrimSpace(param), " ", "") == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(target), "<>")
+			}
+		}
+	}
+	return ""
+}
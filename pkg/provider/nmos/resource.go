@@ -0,0 +1,178 @@
+package nmos
+
+import (
+	"strings"
+
+	"github.com/lukirs95/monika-gosdk/pkg/types"
+)
+
+// TAG_GROUPHINT groups senders and receivers of a device, see AMWA BCP-002-01.
+// Its values have the form "<group-name>:<role-in-group>".
+const TAG_GROUPHINT = "urn:x-nmos:tag:grouphint/v1.0"
+
+const (
+	FORMAT_VIDEO = "urn:x-nmos:format:video"
+	FORMAT_AUDIO = "urn:x-nmos:format:audio"
+	FORMAT_DATA  = "urn:x-nmos:format:data"
+	FORMAT_MUX   = "urn:x-nmos:format:mux"
+)
+
+// CONTROL_CONNECTION is the control type of the IS-05 Connection API.
+const CONTROL_CONNECTION = "urn:x-nmos:control:sr-ctrl"
+
+type Node struct {
+	Id    string  `json:"id"`
+	Label string  `json:"label"`
+	Href  string  `json:"href"`
+	Api   NodeApi `json:"api"`
+}
+
+type NodeApi struct {
+	Versions  []string       `json:"versions"`
+	Endpoints []NodeEndpoint `json:"endpoints"`
+}
+
+type NodeEndpoint struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+}
+
+// endpoint returns the first http endpoint of the node api, if any.
+func (n Node) endpoint() (NodeEndpoint, bool) {
+	for _, endpoint := range n.Api.Endpoints {
+		if endpoint.Protocol == "http" || endpoint.Protocol == "" {
+			return endpoint, true
+		}
+	}
+	if len(n.Api.Endpoints) > 0 {
+		return n.Api.Endpoints[0], true
+	}
+	return NodeEndpoint{}, false
+}
+
+type Device struct {
+	Id       string          `json:"id"`
+	Label    string          `json:"label"`
+	Type     string          `json:"type"`
+	NodeId   string          `json:"node_id"`
+	Controls []DeviceControl `json:"controls"`
+}
+
+type DeviceControl struct {
+	Href string `json:"href"`
+	Type string `json:"type"`
+}
+
+func (d Device) GetId() types.DeviceId {
+	return types.DeviceId(d.Id)
+}
+
+// Name returns the label of the device, or its id if it has none.
+func (d Device) Name() string {
+	return name(d.Label, d.Id)
+}
+
+// ConnectionAPI returns the href of the IS-05 Connection API of the device.
+func (d Device) ConnectionAPI() string {
+	for _, control := range d.Controls {
+		if strings.HasPrefix(control.Type, CONTROL_CONNECTION) {
+			return control.Href
+		}
+	}
+	return ""
+}
+
+type Flow struct {
+	Id     string `json:"id"`
+	Format string `json:"format"`
+}
+
+type Sender struct {
+	Id           string              `json:"id"`
+	Label        string              `json:"label"`
+	Tags         map[string][]string `json:"tags"`
+	DeviceId     string              `json:"device_id"`
+	FlowId       string              `json:"flow_id"`
+	Transport    string              `json:"transport"`
+	ManifestHref string              `json:"manifest_href"`
+	Subscription SenderSubscription  `json:"subscription"`
+}
+
+// Name returns the label of the sender, or its id if it has none.
+func (s Sender) Name() string {
+	return name(s.Label, s.Id)
+}
+
+type SenderSubscription struct {
+	ReceiverId string `json:"receiver_id"`
+	Active     bool   `json:"active"`
+}
+
+type Receiver struct {
+	Id           string               `json:"id"`
+	Label        string               `json:"label"`
+	Tags         map[string][]string  `json:"tags"`
+	DeviceId     string               `json:"device_id"`
+	Format       string               `json:"format"`
+	Transport    string               `json:"transport"`
+	Subscription ReceiverSubscription `json:"subscription"`
+}
+
+// Name returns the label of the receiver, or its id if it has none.
+func (r Receiver) Name() string {
+	return name(r.Label, r.Id)
+}
+
+type ReceiverSubscription struct {
+	SenderId string `json:"sender_id"`
+	Active   bool   `json:"active"`
+}
+
+// name falls back to the id of resources registered without label, which
+// IS-04 allows.
+func name(label string, id string) string {
+	if label == "" {
+		return id
+	}
+	return label
+}
+
+// group returns the group name of the first grouphint tag.
+func group(tags map[string][]string) string {
+	for _, hint := range tags[TAG_GROUPHINT] {
+		if name, _, _ := strings.Cut(hint, ":"); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+func ioletType(format string, sender bool) types.IOletType {
+	switch format {
+	case FORMAT_VIDEO:
+		if sender {
+			return types.IOletType_IPVIDEOOUT
+		}
+		return types.IOletType_IPVIDEOIN
+	case FORMAT_AUDIO:
+		if sender {
+			return types.IOletType_IPAUDIOOUT
+		}
+		return types.IOletType_IPAUDIOIN
+	}
+	return types.IOletType_IPDATA
+}
+
+// formatName names the module of ungrouped senders and receivers.
+func formatName(format string) string {
+	switch format {
+	case FORMAT_VIDEO:
+		return "video"
+	case FORMAT_AUDIO:
+		return "audio"
+	case FORMAT_MUX:
+		return "mux"
+	}
+	return "data"
+}
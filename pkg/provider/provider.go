@@ -2,12 +2,17 @@ package provider
 
 import (
 	"context"
+	"net/url"
 
 	"github.com/lukirs95/monika-gosdk/pkg/provider/netbox"
+	"github.com/lukirs95/monika-gosdk/pkg/provider/nmos"
 	"github.com/lukirs95/monika-gosdk/pkg/types"
 )
 
 type DeviceProvider interface {
+	// FetchDevices is called again on every refresh of the driver. Known devices
+	// keep their reference and are updated in place, devices which do not
+	// exist anymore are dropped.
 	FetchDevices(context.Context) error
 	// GetDevices is called by the driver. A second call MUST return the same references!
 	GetDevices() []types.Device
@@ -19,3 +24,9 @@ type DeviceProvider interface {
 func NewDeviceProviderNetbox(server string, apiKey string, deviceType types.DeviceType, deviceTypeID int) DeviceProvider {
 	return netbox.NewNetbox(server, apiKey, deviceType, deviceTypeID)
 }
+
+// NewDeviceProviderNmos returns a DeviceProvider which discovers devices from the Query API of an NMOS IS-04 registry.
+// query selects the NMOS devices of the driver, nil selects all.
+func NewDeviceProviderNmos(queryAPI string, deviceType types.DeviceType, query url.Values) DeviceProvider {
+	return nmos.NewNmos(queryAPI, deviceType, query)
+}
@@ -2,8 +2,15 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/joho/godotenv"
@@ -28,3 +35,359 @@ func TestNetbox(t *testing.T) {
 
 	t.Log(provider.GetDevices())
 }
+
+func TestNetboxRefresh(t *testing.T) {
+	pages := []string{
+		`{"id": 1, "name": "UPS 1", "primary_ip": {"family": 4, "address": "192.168.1.20/24"}, "custom_fields": {"MONIKA": true}}`,
+		`{"id": 2, "name": "UPS 2", "primary_ip": {"family": 4, "address": "192.168.1.21/24"}, "custom_fields": {"MONIKA": true}}`,
+	}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		next := ""
+		if offset+1 < len(pages) {
+			next = fmt.Sprintf("%s/api/dcim/devices/?offset=%d", server.URL, offset+1)
+		}
+		fmt.Fprintf(w, `{"count": %d, "next": %q, "results": [%s]}`, len(pages), next, pages[offset])
+	}))
+	defer server.Close()
+
+	provider := NewDeviceProviderNetbox(server.URL, "key", types.DeviceType_GENERIC_USV, 1)
+	if err := provider.FetchDevices(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	devices := provider.GetDevices()
+	if len(devices) != 2 || devices[0].GetId() != "1" || devices[1].GetId() != "2" {
+		t.Fatalf("expected the devices of all pages, got %v", devices)
+	}
+
+	pages = []string{
+		`{"id": 1, "name": "UPS 1a", "primary_ip": {"family": 4, "address": "192.168.1.30/24"}, "custom_fields": {"MONIKA": true}}`,
+		`{"id": 3, "name": "UPS 3", "primary_ip": {"family": 4, "address": "192.168.1.22/24"}, "custom_fields": {"MONIKA": true}}`,
+	}
+	if err := provider.FetchDevices(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	refreshed := provider.GetDevices()
+	if len(refreshed) != 2 || refreshed[0] != devices[0] || refreshed[1].GetId() != "3" {
+		t.Fatalf("refresh should keep known, add new and drop removed devices, got %v", refreshed)
+	}
+	if refreshed[0].GetName() != "UPS 1a" || refreshed[0].GetControlIP() != "192.168.1.30" {
+		t.Errorf("known device should be updated in place, got %s %s", refreshed[0].GetName(), refreshed[0].GetControlIP())
+	}
+}
+
+// fakeNmos records the staged IS-05 patches by path, prefixed with "moved/"
+// for the Connection API under /moved. Its resources and devices can be
+// changed between fetches.
+type fakeNmos struct {
+	*httptest.Server
+	mutex     sync.Mutex
+	patches   map[string]map[string]any
+	resources map[string]string
+	devices   []string
+}
+
+func (fake *fakeNmos) setDevice(index int, body string) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.devices[index] = body
+}
+
+func (fake *fakeNmos) setResource(resource string, body string) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.resources[resource] = body
+}
+
+func (fake *fakeNmos) patch(path string) map[string]any {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	return fake.patches[path]
+}
+
+// fakeRegistry serves a minimal NMOS IS-04 Query API with two devices, the
+// devices endpoint paged one device per page, and their IS-05 Connection API.
+func fakeRegistry() *fakeNmos {
+	var server *httptest.Server
+	fake := &fakeNmos{patches: make(map[string]map[string]any)}
+	fake.resources = map[string]string{
+		"nodes": `[{"id": "node-1", "label": "Camera Node", "api": {"versions": ["v1.3"], "endpoints": [{"host": "192.168.1.10", "port": 80, "protocol": "http"}]}}]`,
+		"flows": `[{"id": "flow-video", "format": "urn:x-nmos:format:video"}, {"id": "flow-audio", "format": "urn:x-nmos:format:audio"}, {"id": "flow-data", "format": "urn:x-nmos:format:data"}]`,
+		"senders": `[
+			{"id": "sender-video", "label": "Video Out", "device_id": "device-1", "flow_id": "flow-video", "manifest_href": "MANIFEST", "subscription": {"receiver_id": null, "active": true},
+				"tags": {"urn:x-nmos:tag:grouphint/v1.0": ["Camera 1:Video"]}},
+			{"id": "sender-audio", "label": "Audio Out", "device_id": "device-1", "flow_id": "flow-audio", "subscription": {"receiver_id": null, "active": false}},
+			{"id": "sender-data", "label": "", "device_id": "device-1", "flow_id": "flow-data", "subscription": {"receiver_id": null, "active": false}}
+		]`,
+		"receivers": `[
+			{"id": "receiver-video", "label": "Video In", "device_id": "device-2", "format": "urn:x-nmos:format:video", "subscription": {"sender_id": "sender-video", "active": true}},
+			{"id": "receiver-data", "label": "Data In", "device_id": "device-2", "format": "urn:x-nmos:format:data", "subscription": {"sender_id": null, "active": false}}
+		]`,
+	}
+	fake.devices = []string{
+		`{"id": "device-1", "label": "Camera 1", "type": "urn:x-nmos:device:pipeline", "node_id": "node-1",
+			"controls": [{"href": "CONNECTION", "type": "urn:x-nmos:control:sr-ctrl/v1.1"}]}`,
+		`{"id": "device-2", "label": "Monitor 1", "type": "urn:x-nmos:device:pipeline", "node_id": "node-2",
+			"controls": [{"href": "CONNECTION", "type": "urn:x-nmos:control:sr-ctrl/v1.1"}]}`,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/x-nmos/query/v1.3/", func(w http.ResponseWriter, r *http.Request) {
+		resource := strings.TrimPrefix(r.URL.Path, "/x-nmos/query/v1.3/")
+		if resource == "devices" {
+			since, _ := strconv.Atoi(strings.Split(r.URL.Query().Get("paging.since"), ":")[0])
+			w.Header().Set("Link", fmt.Sprintf(`<%s/x-nmos/query/v1.3/devices?paging.since=%d:0>; rel="next"`, server.URL, since+1))
+			fake.mutex.Lock()
+			defer fake.mutex.Unlock()
+			if since < len(fake.devices) {
+				device := strings.ReplaceAll(fake.devices[since], "CONNECTION", server.URL+"/x-nmos/connection/v1.1/")
+				fmt.Fprintf(w, "[%s]", strings.ReplaceAll(device, "MOVED", server.URL+"/moved/x-nmos/connection/v1.1/"))
+			} else {
+				fmt.Fprint(w, "[]")
+			}
+			return
+		}
+		fake.mutex.Lock()
+		body, ok := fake.resources[resource]
+		fake.mutex.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, strings.ReplaceAll(body, "MANIFEST", server.URL+"/manifest"))
+	})
+	mux.HandleFunc("/manifest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "v=0\r\no=- 1 1 IN IP4 192.168.1.10\r\ns=Video Out\r\nt=0 0\r\nm=video 50000 RTP/AVP 96\r\nc=IN IP4 239.100.1.1/64\r\na=rtpmap:96 raw/90000\r\n")
+	})
+	connection := func(w http.ResponseWriter, r *http.Request) {
+		prefix, path, _ := strings.Cut(r.URL.Path, "/x-nmos/connection/v1.1/single/")
+		if prefix != "" {
+			path = strings.TrimPrefix(prefix, "/") + "/" + path
+		}
+		if r.Method == http.MethodGet && strings.HasSuffix(path, "/transportfile") {
+			fmt.Fprint(w, "v=0\r\no=- 2 2 IN IP4 192.168.1.10\r\ns=Video Out\r\nt=0 0\r\nm=video 50000 RTP/AVP 96\r\nc=IN IP4 239.100.1.2/64\r\na=rtpmap:96 raw/90000\r\n")
+			return
+		}
+		var staged map[string]any
+		if r.Method != http.MethodPatch || json.NewDecoder(r.Body).Decode(&staged) != nil {
+			http.Error(w, `{"code": 400, "error": "invalid request"}`, http.StatusBadRequest)
+			return
+		}
+		fake.mutex.Lock()
+		fake.patches[path] = staged
+		fake.mutex.Unlock()
+		json.NewEncoder(w).Encode(staged)
+	}
+	mux.HandleFunc("/x-nmos/connection/v1.1/single/", connection)
+	mux.HandleFunc("/moved/x-nmos/connection/v1.1/single/", connection)
+	server = httptest.NewServer(mux)
+	fake.Server = server
+	return fake
+}
+
+func TestNmos(t *testing.T) {
+	registry := fakeRegistry()
+	defer registry.Close()
+
+	provider := NewDeviceProviderNmos(registry.URL+"/x-nmos/query/v1.3", types.DeviceType__GENERIC_DUMMY, nil)
+	if err := provider.FetchDevices(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	devices := provider.GetDevices()
+	if len(devices) != 2 {
+		t.Fatalf("expected 2 devices, got %d", len(devices))
+	}
+
+	camera := devices[0]
+	if camera.GetId() != "device-1" || camera.GetName() != "Camera 1" || camera.GetControlIP() != "192.168.1.10" || camera.GetControlPort() != 80 {
+		t.Errorf("unexpected device %s %s %s:%d", camera.GetId(), camera.GetName(), camera.GetControlIP(), camera.GetControlPort())
+	}
+	if !camera.GetStatus().ONLINE() {
+		t.Error("registered device should be online")
+	}
+	video := camera.GetModule("Camera 1")
+	if video == nil {
+		t.Fatal("grouphint should name the module")
+	}
+	sender := video.GetIOlet("sender-video")
+	if sender == nil || sender.GetType() != types.IOletType_IPVIDEOOUT || !sender.GetStatus().Running() {
+		t.Fatal("active video sender should be a running video output")
+	}
+	if stream := types.StreamOf(sender); stream == nil || stream.Media[0].Destination != "239.100.1.1" {
+		t.Error("sender should carry the stream of its manifest")
+	}
+	audio := camera.GetModule("audio")
+	if audio == nil || audio.GetIOlet("sender-audio") == nil || audio.GetIOlet("sender-audio").GetType() != types.IOletType_IPAUDIOOUT {
+		t.Error("ungrouped audio sender should be grouped by format")
+	}
+	if data := camera.GetModule("data"); data == nil || data.GetIOlet("sender-data") == nil || data.GetIOlet("sender-data").GetName() != "sender-data" {
+		t.Error("unlabeled sender should be named by its id")
+	}
+
+	monitor := devices[1]
+	if monitor.GetControlIP() != "" {
+		t.Error("device of an unknown node should have no control ip")
+	}
+	if monitor.GetModule("data") == nil || monitor.GetModule("video") == nil {
+		t.Fatal("receivers should be grouped by format")
+	}
+	routes := monitor.GetRoutes()
+	expected := types.Route{
+		Source:      types.IOletAddress{DeviceId: "device-1", ModuleId: "Camera 1", IOletId: "sender-video"},
+		Destination: types.IOletAddress{DeviceId: "device-2", ModuleId: "video", IOletId: "receiver-video"},
+	}
+	if len(routes) != 1 || routes[0] != expected {
+		t.Errorf("expected route %v, got %v", expected, routes)
+	}
+
+	if err := provider.FetchDevices(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(provider.GetDevices()) != 2 || provider.GetDevices()[0] != camera {
+		t.Error("a second fetch should keep the known devices")
+	}
+}
+
+func TestNmosRefresh(t *testing.T) {
+	registry := fakeRegistry()
+	defer registry.Close()
+
+	provider := NewDeviceProviderNmos(registry.URL+"/x-nmos/query/v1.3", types.DeviceType__GENERIC_DUMMY, nil)
+	if err := provider.FetchDevices(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	monitor := provider.GetDevices()[1]
+	video := monitor.GetModule("video").GetIOlet("receiver-video")
+
+	registry.setResource("receivers", `[
+		{"id": "receiver-video", "label": "Video In", "device_id": "device-2", "format": "urn:x-nmos:format:video", "subscription": {"sender_id": "sender-video", "active": true}},
+		{"id": "receiver-audio", "label": "Audio In", "device_id": "device-2", "format": "urn:x-nmos:format:audio", "subscription": {"sender_id": "sender-audio", "active": true}}
+	]`)
+	if err := provider.FetchDevices(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if provider.GetDevices()[1] != monitor {
+		t.Fatal("known device should be kept")
+	}
+	if monitor.GetModule("video").GetIOlet("receiver-video") != video {
+		t.Error("known receiver should be kept")
+	}
+	audio := monitor.GetModule("audio")
+	if audio == nil || audio.GetIOlet("receiver-audio") == nil {
+		t.Fatal("added receiver should be in its format module")
+	}
+	if len(audio.GetIOlet("receiver-audio").GetControls()) != 2 {
+		t.Error("added receiver should get the connection actions")
+	}
+	if monitor.GetModule("data") != nil {
+		t.Error("module of the vanished receiver should be removed")
+	}
+	if routes := monitor.GetRoutes(); len(routes) != 2 {
+		t.Errorf("expected routes of both receivers, got %v", routes)
+	}
+	if err := types.Validate(provider.GetDevices()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNmosConnectionMoved(t *testing.T) {
+	registry := fakeRegistry()
+	defer registry.Close()
+
+	provider := NewDeviceProviderNmos(registry.URL+"/x-nmos/query/v1.3", types.DeviceType__GENERIC_DUMMY, nil)
+	if err := provider.FetchDevices(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	camera, monitor := provider.GetDevices()[0], provider.GetDevices()[1]
+	sender := camera.GetModule("Camera 1").GetIOlet("sender-video")
+
+	registry.setDevice(0, `{"id": "device-1", "label": "Camera 1", "type": "urn:x-nmos:device:pipeline", "node_id": "node-1",
+		"controls": [{"href": "MOVED", "type": "urn:x-nmos:control:sr-ctrl/v1.1"}]}`)
+	registry.setDevice(1, `{"id": "device-2", "label": "Monitor 1", "type": "urn:x-nmos:device:pipeline", "node_id": "node-2", "controls": []}`)
+	if err := provider.FetchDevices(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if camera.GetModule("Camera 1").GetIOlet("sender-video") != sender {
+		t.Fatal("known sender should be kept")
+	}
+	if err := sender.FireAction(context.Background(), types.IOletControl_START); err != nil {
+		t.Fatal(err)
+	}
+	if registry.patch("moved/senders/sender-video/staged") == nil || registry.patch("senders/sender-video/staged") != nil {
+		t.Error("START should patch the moved Connection API")
+	}
+
+	receiver := monitor.GetModule("video").GetIOlet("receiver-video")
+	if len(receiver.GetControls()) != 0 || monitor.CanRoute() {
+		t.Errorf("device without Connection API should have no connection controls, got %v", receiver.GetControls())
+	}
+	if err := receiver.FireAction(context.Background(), types.IOletControl_START); !errors.Is(err, types.ErrControlNotSupported) {
+		t.Errorf("START without Connection API should not be supported, got %v", err)
+	}
+}
+
+func TestNmosConnection(t *testing.T) {
+	registry := fakeRegistry()
+	defer registry.Close()
+
+	provider := NewDeviceProviderNmos(registry.URL+"/x-nmos/query/v1.3", types.DeviceType__GENERIC_DUMMY, nil)
+	if err := provider.FetchDevices(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	camera, monitor := provider.GetDevices()[0], provider.GetDevices()[1]
+
+	sender := camera.GetModule("Camera 1").GetIOlet("sender-video")
+	if err := sender.FireAction(context.Background(), types.IOletControl_STOP); err != nil {
+		t.Fatal(err)
+	}
+	staged := registry.patch("senders/sender-video/staged")
+	if staged["master_enable"] != false || staged["activation"].(map[string]any)["mode"] != "activate_immediate" {
+		t.Errorf("unexpected sender patch %v", staged)
+	}
+	if sender.GetStatus().Running() {
+		t.Error("stopped sender should not be running")
+	}
+	if err := sender.FireAction(context.Background(), types.IOletControl_START); err != nil {
+		t.Fatal(err)
+	}
+	if stream := types.StreamOf(sender); stream == nil || stream.Media[0].Destination != "239.100.1.2" {
+		t.Error("started sender should report its transport file")
+	}
+
+	route := types.Route{
+		Source:      types.IOletAddress{DeviceId: "device-1", ModuleId: "Camera 1", IOletId: "sender-video"},
+		Destination: types.IOletAddress{DeviceId: "device-2", ModuleId: "data", IOletId: "receiver-data"},
+	}
+	if err := monitor.FireRouteAction(context.Background(), route); err != nil {
+		t.Fatal(err)
+	}
+	staged = registry.patch("receivers/receiver-data/staged")
+	transportFile, _ := staged["transport_file"].(map[string]any)
+	if staged["sender_id"] != "sender-video" || staged["master_enable"] != true || !strings.Contains(fmt.Sprint(transportFile["data"]), "239.100.1.1") {
+		t.Errorf("unexpected receiver patch %v", staged)
+	}
+	if stored, ok := monitor.GetRoute(route.Destination); !ok || stored != route {
+		t.Error("connected route should be stored")
+	}
+	if !monitor.GetModule("data").GetIOlet("receiver-data").GetStatus().Running() {
+		t.Error("connected receiver should be running")
+	}
+
+	if err := monitor.FireRouteAction(context.Background(), types.Route{Destination: route.Destination}); err != nil {
+		t.Fatal(err)
+	}
+	staged = registry.patch("receivers/receiver-data/staged")
+	if value, ok := staged["sender_id"]; !ok || value != nil || staged["master_enable"] != false {
+		t.Errorf("unexpected disconnect patch %v", staged)
+	}
+	if _, ok := monitor.GetRoute(route.Destination); ok {
+		t.Error("disconnected route should be cleared")
+	}
+
+	unknown := route
+	unknown.Source.IOletId = "sender-unknown"
+	if err := monitor.FireRouteAction(context.Background(), unknown); err == nil {
+		t.Error("route from an unknown sender should fail")
+	}
+}
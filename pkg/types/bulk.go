@@ -0,0 +1,203 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// BULK_CONCURRENCY is the default number of controls a bulk control runs
+	// at once, BULK_MAX_CONCURRENCY the most a request may ask for
+	BULK_CONCURRENCY     = 8
+	BULK_MAX_CONCURRENCY = 32
+	// BULK_TIMEOUT is the default time each control of a bulk control may take
+	BULK_TIMEOUT = 30 * time.Second
+)
+
+type ElementLevel string
+
+const (
+	ElementLevel_DEVICE ElementLevel = "device"
+	ElementLevel_MODULE ElementLevel = "module"
+	ElementLevel_IOLET  ElementLevel = "iolet"
+)
+
+// ElementPath addresses a device, a module or an iolet. A path to a device
+// leaves the module and iolet fields empty, a path to a module the iolet
+// fields. Types are optional and checked if set.
+type ElementPath struct {
+	DeviceId   DeviceId   `json:"deviceId"`
+	ModuleType ModuleType `json:"moduleType,omitempty"`
+	ModuleId   ModuleId   `json:"moduleId,omitempty"`
+	IOletType  IOletType  `json:"ioletType,omitempty"`
+	IOletId    IOletId    `json:"ioletId,omitempty"`
+}
+
+func (path ElementPath) Level() ElementLevel {
+	switch {
+	case path.IOletId != "":
+		return ElementLevel_IOLET
+	case path.ModuleId != "":
+		return ElementLevel_MODULE
+	}
+	return ElementLevel_DEVICE
+}
+
+func (path ElementPath) String() string {
+	switch path.Level() {
+	case ElementLevel_IOLET:
+		return fmt.Sprintf("%s/%s/%s", path.DeviceId, path.ModuleId, path.IOletId)
+	case ElementLevel_MODULE:
+		return fmt.Sprintf("%s/%s", path.DeviceId, path.ModuleId)
+	}
+	return string(path.DeviceId)
+}
+
+// ElementSelector selects the elements of Level matching all fields which are
+// set, e.g. every iolet of module 3 of device 1 or every device of redundancy
+// group "studio-a". Drivers do not know the groups of the gateway, the gateway
+// passes the members of such a group as DeviceIds.
+type ElementSelector struct {
+	Level     ElementLevel `json:"level"`
+	DeviceIds []DeviceId   `json:"deviceIds,omitempty"`
+	// RedundancyGroup selects the devices of a redundancy group by its id
+	RedundancyGroup string     `json:"redundancyGroup,omitempty"`
+	ModuleType      ModuleType `json:"moduleType,omitempty"`
+	ModuleIds       []ModuleId `json:"moduleIds,omitempty"`
+	IOletType       IOletType  `json:"ioletType,omitempty"`
+}
+
+func (selector ElementSelector) Valid() error {
+	switch selector.Level {
+	case ElementLevel_DEVICE:
+		if selector.ModuleType != "" || len(selector.ModuleIds) > 0 || selector.IOletType != "" {
+			return errors.New("device selector can not filter modules or iolets")
+		}
+	case ElementLevel_MODULE:
+		if selector.IOletType != "" {
+			return errors.New("module selector can not filter iolets")
+		}
+	case ElementLevel_IOLET:
+	default:
+		return fmt.Errorf("unknown level %q", selector.Level)
+	}
+	return nil
+}
+
+// Select returns the paths of the selected elements of devices.
+func (selector ElementSelector) Select(devices []Device) []ElementPath {
+	paths := make([]ElementPath, 0)
+	for _, device := range devices {
+		deviceId := device.GetId()
+		if len(selector.DeviceIds) > 0 && !slices.Contains(selector.DeviceIds, deviceId) {
+			continue
+		}
+		if selector.RedundancyGroup != "" {
+			group := device.GetRedundancyGroup()
+			if group == nil || group.GetId() != selector.RedundancyGroup {
+				continue
+			}
+		}
+		if selector.Level == ElementLevel_DEVICE {
+			paths = append(paths, ElementPath{DeviceId: deviceId})
+			continue
+		}
+		for _, module := range device.GetModules() {
+			moduleId, moduleType := module.GetId(), module.GetType()
+			if selector.ModuleType != "" && moduleType != selector.ModuleType {
+				continue
+			}
+			if len(selector.ModuleIds) > 0 && !slices.Contains(selector.ModuleIds, moduleId) {
+				continue
+			}
+			if selector.Level == ElementLevel_MODULE {
+				paths = append(paths, ElementPath{DeviceId: deviceId, ModuleType: moduleType, ModuleId: moduleId})
+				continue
+			}
+			for _, iolet := range module.GetIOlets() {
+				if selector.IOletType != "" && iolet.GetType() != selector.IOletType {
+					continue
+				}
+				paths = append(paths, ElementPath{
+					DeviceId:   deviceId,
+					ModuleType: moduleType,
+					ModuleId:   moduleId,
+					IOletType:  iolet.GetType(),
+					IOletId:    iolet.GetId(),
+				})
+			}
+		}
+	}
+	return paths
+}
+
+// BulkControl runs one control on the elements of Targets and those selected
+// by Selector. The control has to fit the level of every element.
+type BulkControl struct {
+	Control   string           `json:"control"`
+	Arguments Arguments        `json:"arguments,omitempty"`
+	Targets   []ElementPath    `json:"targets,omitempty"`
+	Selector  *ElementSelector `json:"selector,omitempty"`
+	// Concurrency limits the controls running at once, zero uses
+	// BULK_CONCURRENCY
+	Concurrency int `json:"concurrency,omitempty"`
+	// TimeoutMs limits every single control in milliseconds, zero uses
+	// BULK_TIMEOUT
+	TimeoutMs int64 `json:"timeoutMs,omitempty"`
+}
+
+// Timeout returns the time every single control may take.
+func (bulk BulkControl) Timeout() time.Duration {
+	if bulk.TimeoutMs == 0 {
+		return BULK_TIMEOUT
+	}
+	return time.Duration(bulk.TimeoutMs) * time.Millisecond
+}
+
+func (bulk BulkControl) Valid() error {
+	var problems []string
+	if bulk.Control == "" {
+		problems = append(problems, "control is missing")
+	}
+	if len(bulk.Targets) == 0 && bulk.Selector == nil {
+		problems = append(problems, "targets or selector are required")
+	}
+	if bulk.Selector != nil {
+		if err := bulk.Selector.Valid(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if bulk.Concurrency < 0 || bulk.Concurrency > BULK_MAX_CONCURRENCY {
+		problems = append(problems, fmt.Sprintf("concurrency must be between 0 and %d", BULK_MAX_CONCURRENCY))
+	}
+	if bulk.TimeoutMs < 0 {
+		problems = append(problems, "timeout must not be negative")
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// ElementResult is the outcome of the control of one element of a bulk
+// control. Status is set when the report is served, e.g. 404 for a missing
+// element.
+type ElementResult struct {
+	Path       ElementPath `json:"path"`
+	Status     int         `json:"status,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	DurationMs int64       `json:"durationMs"`
+	Err        error       `json:"-"`
+}
+
+// BulkControlReport lists the results in the order of the elements, targets
+// before selected elements.
+type BulkControlReport struct {
+	Control   string          `json:"control"`
+	Results   []ElementResult `json:"results"`
+	Succeeded int             `json:"succeeded"`
+	Failed    int             `json:"failed"`
+}
@@ -0,0 +1,71 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestElementSelector(t *testing.T) {
+	devices := make([]Device, 0)
+	for _, deviceId := range []DeviceId{"1", "2"} {
+		device := NewDevice(deviceId, DeviceType__GENERIC_DUMMY, "Encoder")
+		for _, moduleId := range []ModuleId{"1", "2", "3"} {
+			module := NewModule(moduleId, ModuleType_AV, "Channel")
+			module.AddIOlet(NewIOlet("1", IOletType_IPVIDEOIN, "Video In"))
+			module.AddIOlet(NewIOlet("2", IOletType_IPVIDEOOUT, "Video Out"))
+			device.AddModule(module)
+		}
+		device.AddModule(NewModule("gpio", ModuleType_GPIO, "GPIO"))
+		devices = append(devices, device)
+	}
+	NewRedundancyGroup("studio-a", devices[0], NewDevice("3", DeviceType__GENERIC_DUMMY, "Backup"))
+
+	cases := []struct {
+		selector ElementSelector
+		paths    int
+	}{
+		{ElementSelector{Level: ElementLevel_DEVICE}, 2},
+		{ElementSelector{Level: ElementLevel_DEVICE, DeviceIds: []DeviceId{"2", "3"}}, 1},
+		{ElementSelector{Level: ElementLevel_DEVICE, RedundancyGroup: "studio-a"}, 1},
+		{ElementSelector{Level: ElementLevel_MODULE, RedundancyGroup: "studio-b"}, 0},
+		{ElementSelector{Level: ElementLevel_MODULE, ModuleType: ModuleType_AV}, 6},
+		{ElementSelector{Level: ElementLevel_IOLET, DeviceIds: []DeviceId{"1"}, ModuleIds: []ModuleId{"3"}}, 2},
+		{ElementSelector{Level: ElementLevel_IOLET, IOletType: IOletType_IPVIDEOOUT}, 6},
+	}
+	for _, c := range cases {
+		if err := c.selector.Valid(); err != nil {
+			t.Error(err)
+		}
+		if paths := c.selector.Select(devices); len(paths) != c.paths {
+			t.Errorf("%+v: expected %d paths, got %v", c.selector, c.paths, paths)
+		}
+	}
+
+	paths := ElementSelector{Level: ElementLevel_IOLET, DeviceIds: []DeviceId{"1"}, ModuleIds: []ModuleId{"3"}, IOletType: IOletType_IPVIDEOIN}.Select(devices)
+	expected := ElementPath{DeviceId: "1", ModuleType: ModuleType_AV, ModuleId: "3", IOletType: IOletType_IPVIDEOIN, IOletId: "1"}
+	if len(paths) != 1 || paths[0] != expected || paths[0].Level() != ElementLevel_IOLET || paths[0].String() != "1/3/1" {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+
+	if err := (ElementSelector{Level: ElementLevel_DEVICE, IOletType: IOletType_IPVIDEOIN}).Valid(); err == nil {
+		t.Error("device selector filtering iolets should not be valid")
+	}
+	if err := (ElementSelector{Level: "crosspoint"}).Valid(); err == nil {
+		t.Error("unknown level should not be valid")
+	}
+	if err := (BulkControl{Control: "START"}).Valid(); err == nil {
+		t.Error("bulk control without elements should not be valid")
+	}
+	targets := []ElementPath{{DeviceId: "1"}}
+	if err := (BulkControl{Control: "START", Targets: targets, Concurrency: BULK_MAX_CONCURRENCY + 1}).Valid(); err == nil {
+		t.Error("concurrency above BULK_MAX_CONCURRENCY should not be valid")
+	}
+	var bulk BulkControl
+	if err := json.Unmarshal([]byte(`{"control": "START", "targets": [{"deviceId": "1"}], "timeoutMs": 1500}`), &bulk); err != nil {
+		t.Fatal(err)
+	}
+	if bulk.Timeout() != 1500*time.Millisecond || (BulkControl{}).Timeout() != BULK_TIMEOUT {
+		t.Errorf("unexpected timeout %s", bulk.Timeout())
+	}
+}
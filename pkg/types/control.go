@@ -0,0 +1,269 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type ParameterType string
+
+const (
+	ParameterType_NUMBER ParameterType = "number"
+	ParameterType_ENUM   ParameterType = "enum"
+	ParameterType_STRING ParameterType = "string"
+	ParameterType_BOOL   ParameterType = "bool"
+)
+
+// Parameter describes one argument of a control.
+type Parameter struct {
+	Name        string        `json:"name"`
+	Type        ParameterType `json:"type"`
+	Description string        `json:"description,omitempty"`
+	// Unit of a number, e.g. "dB"
+	Unit string `json:"unit,omitempty"`
+	// Min and Max bound a number, nil means unbounded
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+	// Values lists the allowed values of an enum
+	Values []string `json:"values,omitempty"`
+	// Optional parameters may be omitted, Default is used instead if set
+	Optional bool `json:"optional,omitempty"`
+	Default  any  `json:"default,omitempty"`
+}
+
+// NewNumberParameter returns a number parameter bounded by min and max.
+func NewNumberParameter(name string, unit string, min float64, max float64) Parameter {
+	return Parameter{Name: name, Type: ParameterType_NUMBER, Unit: unit, Min: &min, Max: &max}
+}
+
+func NewEnumParameter(name string, values ...string) Parameter {
+	return Parameter{Name: name, Type: ParameterType_ENUM, Values: values}
+}
+
+func NewStringParameter(name string) Parameter {
+	return Parameter{Name: name, Type: ParameterType_STRING}
+}
+
+func NewBoolParameter(name string) Parameter {
+	return Parameter{Name: name, Type: ParameterType_BOOL}
+}
+
+// Validate checks value against the parameter and returns it normalized, i.e.
+// numbers are always float64.
+func (parameter Parameter) Validate(value any) (any, error) {
+	switch parameter.Type {
+	case ParameterType_NUMBER:
+		number, ok := toFloat(value)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a number", parameter.Name)
+		}
+		if parameter.Min != nil && number < *parameter.Min {
+			return nil, fmt.Errorf("%s must be at least %g", parameter.Name, *parameter.Min)
+		}
+		if parameter.Max != nil && number > *parameter.Max {
+			return nil, fmt.Errorf("%s must be at most %g", parameter.Name, *parameter.Max)
+		}
+		return number, nil
+	case ParameterType_ENUM:
+		text, ok := value.(string)
+		if !ok || !slices.Contains(parameter.Values, text) {
+			return nil, fmt.Errorf("%s must be one of %v", parameter.Name, parameter.Values)
+		}
+		return text, nil
+	case ParameterType_STRING:
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a string", parameter.Name)
+		}
+		return text, nil
+	case ParameterType_BOOL:
+		flag, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a bool", parameter.Name)
+		}
+		return flag, nil
+	}
+	return nil, fmt.Errorf("%s has unknown type %s", parameter.Name, parameter.Type)
+}
+
+func toFloat(value any) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case float32:
+		return float64(number), true
+	case int:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case json.Number:
+		float, err := number.Float64()
+		return float, err == nil
+	}
+	return 0, false
+}
+
+// Parameters is the argument schema of a control.
+type Parameters []Parameter
+
+// Bind validates args against the schema and returns them normalized with
+// defaults filled in. Unknown and missing required arguments are rejected.
+func (parameters Parameters) Bind(args Arguments) (Arguments, error) {
+	bound := make(Arguments)
+	for name := range args {
+		if !slices.ContainsFunc(parameters, func(parameter Parameter) bool { return parameter.Name == name }) {
+			return nil, fmt.Errorf("unknown argument %s", name)
+		}
+	}
+	for _, parameter := range parameters {
+		value, ok := args[parameter.Name]
+		if !ok {
+			if !parameter.Optional {
+				return nil, fmt.Errorf("missing argument %s", parameter.Name)
+			}
+			if parameter.Default != nil {
+				bound[parameter.Name] = parameter.Default
+			}
+			continue
+		}
+		normalized, err := parameter.Validate(value)
+		if err != nil {
+			return nil, err
+		}
+		bound[parameter.Name] = normalized
+	}
+	return bound, nil
+}
+
+// Arguments are the values passed to a control, keyed by parameter name.
+// Actions receive them validated by the schema of the control.
+type Arguments map[string]any
+
+func (args Arguments) Number(name string) float64 {
+	number, _ := toFloat(args[name])
+	return number
+}
+
+func (args Arguments) String(name string) string {
+	text, _ := args[name].(string)
+	return text
+}
+
+func (args Arguments) Bool(name string) bool {
+	flag, _ := args[name].(bool)
+	return flag
+}
+
+// Has reports whether an optional argument without default was given.
+func (args Arguments) Has(name string) bool {
+	_, ok := args[name]
+	return ok
+}
+
+// ErrControlNotSupported is returned when an element has no action for a
+// control.
+var ErrControlNotSupported = errors.New("control not supported")
+
+// ControlDefinition describes a control to the gateway. Drivers register
+// definitions for their own controls, the built-in ones are pre-registered.
+type ControlDefinition struct {
+	Control     string `json:"control"`
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
+}
+
+type controlRegistry struct {
+	mutex       sync.RWMutex
+	definitions []ControlDefinition
+}
+
+func newControlRegistry(definitions ...ControlDefinition) *controlRegistry {
+	return &controlRegistry{definitions: definitions}
+}
+
+func (registry *controlRegistry) register(definition ControlDefinition) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	index := slices.IndexFunc(registry.definitions, func(known ControlDefinition) bool { return known.Control == definition.Control })
+	if index < 0 {
+		registry.definitions = append(registry.definitions, definition)
+		return
+	}
+	registry.definitions[index] = definition
+}
+
+func (registry *controlRegistry) lookup(control string) (ControlDefinition, bool) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	for _, definition := range registry.definitions {
+		if definition.Control == control {
+			return definition, true
+		}
+	}
+	return ControlDefinition{}, false
+}
+
+func (registry *controlRegistry) list() []ControlDefinition {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	return append([]ControlDefinition(nil), registry.definitions...)
+}
+
+var (
+	deviceControls = newControlRegistry(
+		ControlDefinition{Control: string(DeviceControl_BOOT), Label: "Boot"},
+		ControlDefinition{Control: string(DeviceControl_REBOOT), Label: "Reboot"},
+		ControlDefinition{Control: string(DeviceControl_SHUTDOWN), Label: "Shut down"},
+		ControlDefinition{Control: string(DeviceControl_FAILOVER), Label: "Failover", Description: "Switches to the standby device of a redundancy pair"},
+	)
+	moduleControls = newControlRegistry(
+		ControlDefinition{Control: string(ModuleControl_START), Label: "Start"},
+		ControlDefinition{Control: string(ModuleControl_STOP), Label: "Stop"},
+		ControlDefinition{Control: string(ModuleControl_RESTART), Label: "Restart"},
+	)
+	ioletControls = newControlRegistry(
+		ControlDefinition{Control: string(IOletControl_START), Label: "Start"},
+		ControlDefinition{Control: string(IOletControl_STOP), Label: "Stop"},
+		ControlDefinition{Control: string(IOletControl_RESTART), Label: "Restart"},
+		ControlDefinition{Control: string(IOletControl_SET), Label: "Set", Description: "Latches the output to a level"},
+		ControlDefinition{Control: string(IOletControl_PULSE), Label: "Pulse", Description: "Inverts the output for a duration"},
+		ControlDefinition{Control: string(IOletControl_TOGGLE), Label: "Toggle", Description: "Inverts the output"},
+	)
+)
+
+// RegisterDeviceControl declares a driver specific device control, or
+// replaces the label and description of a known one. It panics if control
+// starts with RESERVED_PREFIX.
+func RegisterDeviceControl(control DeviceControl, label string, description string) {
+	if strings.HasPrefix(string(control), RESERVED_PREFIX) {
+		panic(fmt.Sprintf("device control %s starts with reserved prefix %s", control, RESERVED_PREFIX))
+	}
+	deviceControls.register(ControlDefinition{Control: string(control), Label: label, Description: description})
+}
+
+// RegisterModuleControl declares a driver specific module control.
+func RegisterModuleControl(control ModuleControl, label string, description string) {
+	moduleControls.register(ControlDefinition{Control: string(control), Label: label, Description: description})
+}
+
+// RegisterIOletControl declares a driver specific iolet control.
+func RegisterIOletControl(control IOletControl, label string, description string) {
+	ioletControls.register(ControlDefinition{Control: string(control), Label: label, Description: description})
+}
+
+// DeviceControlDefinitions returns all registered device controls.
+func DeviceControlDefinitions() []ControlDefinition {
+	return deviceControls.list()
+}
+
+func ModuleControlDefinitions() []ControlDefinition {
+	return moduleControls.list()
+}
+
+func IOletControlDefinitions() []ControlDefinition {
+	return ioletControls.list()
+}
@@ -0,0 +1,155 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParametersBind(t *testing.T) {
+	parameters := Parameters{
+		NewNumberParameter("gain", "dB", -60, 12),
+		NewEnumParameter("mode", "mono", "stereo"),
+		{Name: "label", Type: ParameterType_STRING, Optional: true, Default: "none"},
+		{Name: "mute", Type: ParameterType_BOOL, Optional: true},
+	}
+
+	args, err := parameters.Bind(Arguments{"gain": -6, "mode": "stereo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.Number("gain") != -6 || args.String("mode") != "stereo" || args.String("label") != "none" {
+		t.Errorf("unexpected arguments %v", args)
+	}
+	if args.Has("mute") || args.Bool("mute") {
+		t.Error("omitted optional argument without default should not be set")
+	}
+
+	invalid := []Arguments{
+		{"mode": "stereo"},
+		{"gain": -61.0, "mode": "stereo"},
+		{"gain": 13, "mode": "stereo"},
+		{"gain": "loud", "mode": "stereo"},
+		{"gain": 0, "mode": "surround"},
+		{"gain": 0, "mode": "mono", "mute": "yes"},
+		{"gain": 0, "mode": "mono", "unknown": 1},
+	}
+	for _, args := range invalid {
+		if _, err := parameters.Bind(args); err == nil {
+			t.Errorf("arguments %v should be rejected", args)
+		}
+	}
+
+	var decoded Arguments
+	if err := json.NewDecoder(strings.NewReader(`{"gain": 1.5, "mode": "mono", "mute": true}`)).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	args, err = parameters.Bind(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.Number("gain") != 1.5 || !args.Bool("mute") {
+		t.Errorf("unexpected arguments %v", args)
+	}
+}
+
+func TestParameterizedAction(t *testing.T) {
+	iolet := NewIOlet("1", IOletType_IPAUDIOOUT, "Audio Out")
+	var gain float64
+	iolet.AddParameterizedAction("SET_GAIN", Parameters{NewNumberParameter("gain", "dB", -60, 12)}, func(ctx context.Context, iolet IOlet, args Arguments) error {
+		gain = args.Number("gain")
+		return nil
+	})
+	iolet.AddAction(IOletControl_START, func(ctx context.Context, iolet IOlet) error { return nil })
+
+	if err := iolet.FireActionWithArguments(context.Background(), "SET_GAIN", Arguments{"gain": -6}); err != nil {
+		t.Fatal(err)
+	}
+	if gain != -6 {
+		t.Errorf("action should receive gain -6, got %g", gain)
+	}
+	if err := iolet.FireAction(context.Background(), "SET_GAIN"); err == nil {
+		t.Error("missing argument should be rejected")
+	}
+	if err := iolet.FireActionWithArguments(context.Background(), IOletControl_START, Arguments{"gain": 1}); err == nil {
+		t.Error("control without parameters should reject arguments")
+	}
+	if err := iolet.FireAction(context.Background(), IOletControl_START); err != nil {
+		t.Error(err)
+	}
+
+	body, err := json.Marshal(iolet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), `"controlParameters":{"SET_GAIN":[{"name":"gain","type":"number","unit":"dB","min":-60,"max":12}]}`) {
+		t.Errorf("control parameters should be advertised: %s", body)
+	}
+	decoded, err := IOletFromJSON(json.NewDecoder(strings.NewReader(string(body))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parameters := decoded.GetParameters("SET_GAIN"); len(parameters) != 1 || parameters[0].Name != "gain" {
+		t.Error("control parameters should be restored")
+	}
+}
+
+// keepControls restores the control registries when the test ends.
+func keepControls(t *testing.T) {
+	for _, registry := range []*controlRegistry{deviceControls, moduleControls, ioletControls} {
+		registry, definitions := registry, registry.list()
+		t.Cleanup(func() {
+			registry.mutex.Lock()
+			defer registry.mutex.Unlock()
+			registry.definitions = definitions
+		})
+	}
+}
+
+func TestControlRegistry(t *testing.T) {
+	keepControls(t)
+	if err := DeviceControl_REBOOT.Valid(); err != nil {
+		t.Error(err)
+	}
+	if err := DeviceControl("IDENTIFY").Valid(); err == nil {
+		t.Error("unregistered control should not be valid")
+	}
+
+	RegisterDeviceControl("IDENTIFY", "Identify", "Flashes the front panel")
+	RegisterIOletControl(IOletControl_START, "Enable", "")
+	if err := DeviceControl("IDENTIFY").Valid(); err != nil {
+		t.Error(err)
+	}
+	if definition := DeviceControl("IDENTIFY").Definition(); definition.Label != "Identify" || definition.Description != "Flashes the front panel" {
+		t.Errorf("unexpected definition %+v", definition)
+	}
+	if definition := IOletControl_START.Definition(); definition.Label != "Enable" {
+		t.Error("registering a known control should replace its label")
+	}
+	if definition := ModuleControl("FAILOVER").Definition(); definition.Label != "FAILOVER" {
+		t.Error("unregistered controls should be labeled with their name")
+	}
+	definitions := DeviceControlDefinitions()
+	if !slices.ContainsFunc(definitions, func(definition ControlDefinition) bool { return definition.Control == "IDENTIFY" }) {
+		t.Errorf("registered control should be listed, got %v", definitions)
+	}
+}
+
+func TestUnregisteredControlAction(t *testing.T) {
+	module := NewModule("1", ModuleType_AV, "Module")
+	fired := false
+	module.AddAction("SAVE_CONFIG", func(ctx context.Context, module Module) error {
+		fired = true
+		return nil
+	})
+
+	if err := module.FireAction(context.Background(), "SAVE_CONFIG"); err != nil || !fired {
+		t.Error("supported control should fire even if it is not registered")
+	}
+	if err := module.FireAction(context.Background(), ModuleControl_RESTART); !errors.Is(err, ErrControlNotSupported) {
+		t.Errorf("expected ErrControlNotSupported, got %v", err)
+	}
+}
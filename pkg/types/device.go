@@ -4,30 +4,80 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"sync"
 	"sync/atomic"
 )
 
 type Device interface {
 	SetId(newId DeviceId)
 	GetId() DeviceId
+	GetType() DeviceType
 	SetName(string)
 	GetName() string
 	GetStatus() DeviceStatus
 	SetStatus(newStatus DeviceStatus)
+	// ModifyStatus atomically applies modify to the current status.
+	ModifyStatus(modify func(status *DeviceStatus))
 	SetControlIP(controlIP string)
 	GetControlIP() string
 	SetControlPort(controlPort int)
 	GetControlPort() int
 	AddAction(control DeviceControl, callback DeviceAction)
+	// AddParameterizedAction registers an action whose arguments are
+	// validated against parameters before it is called.
+	AddParameterizedAction(control DeviceControl, parameters Parameters, action DeviceParameterizedAction)
+	// GetParameters returns the argument schema of control, nil if it takes
+	// no arguments.
+	GetParameters(control DeviceControl) Parameters
+	GetControls() []DeviceControl
 	FireAction(ctx context.Context, control DeviceControl) error
+	FireActionWithArguments(ctx context.Context, control DeviceControl, args Arguments) error
 	GetModuleTypes() []ModuleType
+	// AddModule adds module. A module with the same id is replaced like by
+	// ReplaceModule, see ModuleAdder to refuse it instead.
 	AddModule(module Module)
+	// RemoveModule removes the module with the given id and returns it, or nil
+	// if there is no such module. The next update reports the removal.
+	RemoveModule(moduleId ModuleId) Module
+	// ReplaceModule replaces the module with the same id, or adds it if there
+	// is none, and returns the old module. The next update reports the new
+	// module completely.
+	ReplaceModule(module Module) Module
 	GetModules() []Module
 	GetModulesByType(moduleType ModuleType) []Module
 	GetModule(moduleId ModuleId) Module
+	// SetRouteAction registers the action which changes the routing of the
+	// device, see RouteAction.
+	SetRouteAction(action RouteAction)
+	CanRoute() bool
+	FireRouteAction(ctx context.Context, route Route) error
+	// SetRoute records the current source of route.Destination as reported by
+	// the device. A route without source clears the destination.
+	SetRoute(route Route)
+	ClearRoute(destination IOletAddress)
+	// GetRoutes returns all routes whose destination is on this device.
+	GetRoutes() []Route
+	GetRoute(destination IOletAddress) (Route, bool)
+	// SetNotifier registers notifier to be told about every change in the
+	// device tree, see DeviceNotifier.
+	SetNotifier(notifier DeviceNotifier)
+	// SetRedundancyGroup is called by NewRedundancyGroup, see
+	// RedundancyGroup.
+	SetRedundancyGroup(group *RedundancyGroup)
+	GetRedundancyGroup() *RedundancyGroup
 	Updated() *DeviceUpdate
 }
 
+// ModuleAdder is implemented by devices which can refuse a module whose id
+// is taken, like those of NewDevice.
+type ModuleAdder interface {
+	// AddModuleChecked adds module, it fails with ErrDuplicateId and leaves
+	// the device unchanged if a module has its id.
+	AddModuleChecked(module Module) error
+}
+
 func NewDevice(id DeviceId, deviceType DeviceType, name string) Device {
 	return &deviceImpl{
 		Id:          id,
@@ -35,103 +85,200 @@ func NewDevice(id DeviceId, deviceType DeviceType, name string) Device {
 		Name:        name,
 		Status:      0,
 		Controls:    make([]DeviceControl, 0),
-		actions:     make(map[DeviceControl]DeviceAction),
+		actions:     make(map[DeviceControl]DeviceParameterizedAction),
+		parameters:  make(map[DeviceControl]Parameters),
 		ModuleTypes: make([]ModuleType, 0),
 		Modules:     make([]Module, 0),
+		modulesById: make(map[ModuleId]Module),
 		modified:    atomic.Bool{},
 	}
 }
 
+// DevicesFromJSON decodes a list of devices including their modules and
+// iolets, as written by DevicesToJSON. Decoded elements have no actions.
 func DevicesFromJSON(decoder *json.Decoder) ([]Device, error) {
-	var rawDevices []deviceImpl
-	if err := decoder.Decode(&rawDevices); err != nil {
+	var snapshots []deviceSnapshot
+	if err := decoder.Decode(&snapshots); err != nil {
 		return nil, err
 	}
 	devices := make([]Device, 0)
-	for index := range rawDevices {
-		devices = append(devices, &rawDevices[index])
+	for _, snapshot := range snapshots {
+		device, err := snapshot.device()
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
 	}
 	return devices, nil
 }
 
+// DeviceFromJSON decodes a single device including its modules and iolets.
 func DeviceFromJSON(decoder *json.Decoder) (Device, error) {
-	device := new(deviceImpl)
-	if err := decoder.Decode(device); err != nil {
+	var snapshot deviceSnapshot
+	if err := decoder.Decode(&snapshot); err != nil {
 		return nil, err
 	}
-	return device, nil
+	return snapshot.device()
+}
+
+// DevicesToJSON encodes the complete tree of every device. Unlike the plain
+// JSON representation of a device, modules and iolets are included.
+func DevicesToJSON(encoder *json.Encoder, devices []Device) error {
+	snapshots := make([]deviceSnapshot, 0)
+	for _, device := range devices {
+		snapshots = append(snapshots, deviceTreeSnapshot(device))
+	}
+	return encoder.Encode(snapshots)
+}
+
+// DeviceToJSON encodes the complete tree of a single device.
+func DeviceToJSON(encoder *json.Encoder, device Device) error {
+	return encoder.Encode(deviceTreeSnapshot(device))
 }
 
 type deviceImpl struct {
-	Id          DeviceId        `json:"deviceId"`
-	Type        DeviceType      `json:"type"`
-	Name        string          `json:"name"`
-	Status      DeviceStatus    `json:"status"`
-	ControlIP   string          `json:"controlIP,omitempty"`
-	ControlPort int             `json:"controlPort,omitempty"`
-	Controls    []DeviceControl `json:"controls"`
-	actions     map[DeviceControl]DeviceAction
-	ModuleTypes []ModuleType `json:"moduleTypes"`
-	Modules     []Module     `json:"-"`
+	mutex       sync.RWMutex
+	Id          DeviceId
+	Type        DeviceType
+	Name        string
+	Status      DeviceStatus
+	ControlIP   string
+	ControlPort int
+	Controls    []DeviceControl
+	actions     map[DeviceControl]DeviceParameterizedAction
+	parameters  map[DeviceControl]Parameters
+	ModuleTypes []ModuleType
+	Modules     []Module
+	// modulesById indexes Modules, for duplicate ids the first module wins
+	modulesById map[ModuleId]Module
 	modified    atomic.Bool
+	// removedModules and replacedModules are reported by the next update
+	removedModules  []ModuleId
+	replacedModules []ModuleId
+	onChange        atomic.Pointer[func()]
+	Routes          []Route
+	routeAction     RouteAction
+	changedRoutes   []IOletAddress
+	redundancy      atomic.Pointer[RedundancyGroup]
 }
 
 type DeviceUpdate struct {
-	Id      DeviceId       `json:"deviceId"`
-	Type    DeviceType     `json:"type"`
-	Name    string         `json:"name"`
-	Status  DeviceStatus   `json:"status"`
-	Modules []ModuleUpdate `json:"modules"`
+	Id             DeviceId       `json:"deviceId"`
+	Type           DeviceType     `json:"type"`
+	Name           string         `json:"name"`
+	Status         DeviceStatus   `json:"status"`
+	Modules        []ModuleUpdate `json:"modules"`
+	RemovedModules []ModuleId     `json:"removedModules,omitempty"`
+	// Routes lists new or changed routes, ClearedRoutes the destinations
+	// which are not routed anymore.
+	Routes        []Route        `json:"routes,omitempty"`
+	ClearedRoutes []IOletAddress `json:"clearedRoutes,omitempty"`
+	// Redundancy is set on every update of a device in a redundancy group
+	Redundancy *RedundancyStatus `json:"redundancy,omitempty"`
+	// Retired is set when the driver does not handle the device anymore
+	Retired bool `json:"retired,omitempty"`
 }
 
 func (device *deviceImpl) SetId(deviceId DeviceId) {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
 	device.Id = deviceId
 }
 
 func (device *deviceImpl) GetId() DeviceId {
+	device.mutex.RLock()
+	defer device.mutex.RUnlock()
 	return device.Id
 }
 
+func (device *deviceImpl) GetType() DeviceType {
+	device.mutex.RLock()
+	defer device.mutex.RUnlock()
+	return device.Type
+}
+
 func (device *deviceImpl) SetName(newName string) {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
 	if device.Name != newName && newName != "" {
 		device.Name = newName
-		device.modified.Store(true)
+		device.markModified()
 	}
 }
 
 func (device *deviceImpl) GetName() string {
+	device.mutex.RLock()
+	defer device.mutex.RUnlock()
 	return device.Name
 }
 
 func (device *deviceImpl) GetStatus() DeviceStatus {
+	device.mutex.RLock()
+	defer device.mutex.RUnlock()
 	return device.Status
 }
 
 func (device *deviceImpl) SetStatus(newStatus DeviceStatus) {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
 	if device.Status != newStatus {
 		device.Status = newStatus
-		device.modified.Store(true)
+		device.markModified()
+		device.statusChanged()
+	}
+}
+
+func (device *deviceImpl) ModifyStatus(modify func(status *DeviceStatus)) {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+	newStatus := device.Status
+	modify(&newStatus)
+	if device.Status != newStatus {
+		device.Status = newStatus
+		device.markModified()
+		device.statusChanged()
 	}
 }
 
 func (device *deviceImpl) SetControlIP(controlIP string) {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
 	device.ControlIP = controlIP
 }
 
 func (device *deviceImpl) GetControlIP() string {
+	device.mutex.RLock()
+	defer device.mutex.RUnlock()
 	return device.ControlIP
 }
 
 func (device *deviceImpl) SetControlPort(controlPort int) {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
 	device.ControlPort = controlPort
 }
 
 func (device *deviceImpl) GetControlPort() int {
+	device.mutex.RLock()
+	defer device.mutex.RUnlock()
 	return device.ControlPort
 }
 
 func (device *deviceImpl) AddAction(newControl DeviceControl, action DeviceAction) {
+	device.AddParameterizedAction(newControl, nil, func(ctx context.Context, device Device, args Arguments) error {
+		return action(ctx, device)
+	})
+}
+
+func (device *deviceImpl) AddParameterizedAction(newControl DeviceControl, parameters Parameters, action DeviceParameterizedAction) {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
 	device.actions[newControl] = action
+	if len(parameters) > 0 {
+		device.parameters[newControl] = parameters
+	} else {
+		delete(device.parameters, newControl)
+	}
 
 	for _, control := range device.Controls {
 		if control == newControl {
@@ -141,13 +288,41 @@ func (device *deviceImpl) AddAction(newControl DeviceControl, action DeviceActio
 	device.Controls = append(device.Controls, newControl)
 }
 
+func (device *deviceImpl) GetControls() []DeviceControl {
+	device.mutex.RLock()
+	defer device.mutex.RUnlock()
+	return append([]DeviceControl(nil), device.Controls...)
+}
+
+func (device *deviceImpl) GetParameters(control DeviceControl) Parameters {
+	device.mutex.RLock()
+	defer device.mutex.RUnlock()
+	return device.parameters[control]
+}
+
 func (device *deviceImpl) FireAction(ctx context.Context, control DeviceControl) error {
-	if action, ok := device.actions[control]; ok {
-		return action(ctx, device)
+	return device.FireActionWithArguments(ctx, control, nil)
+}
+
+// FireActionWithArguments runs the action registered for control. The action
+// is called without holding the device lock, so it may freely modify the
+// device.
+func (device *deviceImpl) FireActionWithArguments(ctx context.Context, control DeviceControl, args Arguments) error {
+	device.mutex.RLock()
+	action, ok := device.actions[control]
+	parameters := device.parameters[control]
+	device.mutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrControlNotSupported, control)
+	}
+	bound, err := parameters.Bind(args)
+	if err != nil {
+		return err
 	}
-	return fmt.Errorf("no such action defined")
+	return action(ctx, device, bound)
 }
 
+// addModuleType expects the caller to hold the write lock.
 func (device *deviceImpl) addModuleType(newModuleType ModuleType) {
 	for _, moduleType := range device.ModuleTypes {
 		if newModuleType == moduleType {
@@ -158,21 +333,120 @@ func (device *deviceImpl) addModuleType(newModuleType ModuleType) {
 }
 
 func (device *deviceImpl) GetModuleTypes() []ModuleType {
-	return device.ModuleTypes
+	device.mutex.RLock()
+	defer device.mutex.RUnlock()
+	return append([]ModuleType(nil), device.ModuleTypes...)
 }
 
 func (device *deviceImpl) AddModule(module Module) {
-	device.addModuleType(module.GetType())
+	device.ReplaceModule(module)
+}
+
+func (device *deviceImpl) AddModuleChecked(module Module) error {
+	moduleId := module.GetId()
+	moduleType := module.GetType()
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+	if _, ok := device.modulesById[moduleId]; ok {
+		return fmt.Errorf("%w: module %s of device %s", ErrDuplicateId, moduleId, device.Id)
+	}
+	device.addModule(moduleId, moduleType, module)
+	return nil
+}
+
+// addModule expects the caller to hold the write lock.
+func (device *deviceImpl) addModule(moduleId ModuleId, moduleType ModuleType, module Module) {
+	device.addModuleType(moduleType)
 	device.Modules = append(device.Modules, module)
+	if _, ok := device.modulesById[moduleId]; !ok {
+		device.modulesById[moduleId] = module
+	}
+	module.SetOnChange(device.notify)
+	if slices.Contains(device.removedModules, moduleId) {
+		// removed and added again before the gateway was told, so the
+		// gateway still knows this module and has to get the new one
+		device.removedModules = slices.DeleteFunc(device.removedModules, func(id ModuleId) bool { return id == moduleId })
+		device.replacedModules = append(device.replacedModules, moduleId)
+		device.notify()
+	}
+}
+
+// removeModuleType expects the caller to hold the write lock.
+func (device *deviceImpl) removeModuleType(oldModuleType ModuleType) {
+	for _, module := range device.Modules {
+		if module.GetType() == oldModuleType {
+			return
+		}
+	}
+	device.ModuleTypes = slices.DeleteFunc(device.ModuleTypes, func(moduleType ModuleType) bool { return moduleType == oldModuleType })
+}
+
+// reindexModule expects the caller to hold the write lock.
+func (device *deviceImpl) reindexModule(moduleId ModuleId) {
+	delete(device.modulesById, moduleId)
+	if index := device.indexOfModule(moduleId); index >= 0 {
+		device.modulesById[moduleId] = device.Modules[index]
+	}
+}
+
+// indexOfModule expects the caller to hold the lock.
+func (device *deviceImpl) indexOfModule(moduleId ModuleId) int {
+	return slices.IndexFunc(device.Modules, func(module Module) bool { return module.GetId() == moduleId })
+}
+
+func (device *deviceImpl) RemoveModule(moduleId ModuleId) Module {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+	index := device.indexOfModule(moduleId)
+	if index < 0 {
+		return nil
+	}
+	removed := device.Modules[index]
+	removed.SetOnChange(nil)
+	device.Modules = slices.Delete(device.Modules, index, index+1)
+	device.reindexModule(moduleId)
+	device.removeModuleType(removed.GetType())
+	device.replacedModules = slices.DeleteFunc(device.replacedModules, func(id ModuleId) bool { return id == moduleId })
+	if !slices.Contains(device.removedModules, moduleId) {
+		device.removedModules = append(device.removedModules, moduleId)
+	}
+	device.notify()
+	return removed
+}
+
+func (device *deviceImpl) ReplaceModule(module Module) Module {
+	moduleId := module.GetId()
+	moduleType := module.GetType()
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+	index := device.indexOfModule(moduleId)
+	if index < 0 {
+		device.addModule(moduleId, moduleType, module)
+		return nil
+	}
+	replaced := device.Modules[index]
+	replaced.SetOnChange(nil)
+	device.Modules[index] = module
+	device.reindexModule(moduleId)
+	module.SetOnChange(device.notify)
+	device.addModuleType(moduleType)
+	device.removeModuleType(replaced.GetType())
+	if !slices.Contains(device.replacedModules, moduleId) {
+		device.replacedModules = append(device.replacedModules, moduleId)
+	}
+	device.notify()
+	return replaced
 }
 
 func (device *deviceImpl) GetModules() []Module {
-	return device.Modules
+	device.mutex.RLock()
+	defer device.mutex.RUnlock()
+	return append([]Module(nil), device.Modules...)
 }
 
 func (device *deviceImpl) GetModulesByType(moduleType ModuleType) []Module {
 	modulesByType := make([]Module, 0)
-	for _, module := range device.Modules {
+	for _, module := range device.GetModules() {
 		if module.GetType() == moduleType {
 			modulesByType = append(modulesByType, module)
 		}
@@ -182,35 +456,195 @@ func (device *deviceImpl) GetModulesByType(moduleType ModuleType) []Module {
 }
 
 func (device *deviceImpl) GetModule(moduleId ModuleId) Module {
-	for _, module := range device.Modules {
-		if module.GetId() == moduleId {
-			return module
-		}
+	device.mutex.RLock()
+	defer device.mutex.RUnlock()
+	return device.modulesById[moduleId]
+}
+
+func (device *deviceImpl) SetNotifier(notifier DeviceNotifier) {
+	if notifier == nil {
+		device.onChange.Store(nil)
+		return
+	}
+	onChange := func() { notifier.Notify(device) }
+	device.onChange.Store(&onChange)
+}
+
+// markModified expects the caller to hold the write lock.
+func (device *deviceImpl) markModified() {
+	device.modified.Store(true)
+	device.notify()
+}
+
+// notify only touches atomics, so it is safe to call with or without locks
+// held anywhere in the tree.
+func (device *deviceImpl) notify() {
+	if onChange := device.onChange.Load(); onChange != nil {
+		(*onChange)()
 	}
-	return nil
 }
 
 func (device *deviceImpl) Updated() *DeviceUpdate {
+	device.mutex.Lock()
+	modules := append([]Module(nil), device.Modules...)
+	removedModules := device.removedModules
+	replacedModules := device.replacedModules
+	device.removedModules = nil
+	device.replacedModules = nil
+	device.mutex.Unlock()
+
 	updatedModules := make([]ModuleUpdate, 0)
-	for _, module := range device.Modules {
+	for _, module := range modules {
+		if slices.Contains(replacedModules, module.GetId()) {
+			updatedModules = append(updatedModules, completeModuleUpdate(module))
+			continue
+		}
 		moduleUpdate := module.Updated()
 		if moduleUpdate != nil {
 			updatedModules = append(updatedModules, *moduleUpdate)
 		}
 	}
 
-	if device.modified.Swap(false) || len(updatedModules) > 0 {
+	redundancy := device.redundancyStatus()
+
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+	routes, clearedRoutes := device.routeUpdates(device.changedRoutes)
+	device.changedRoutes = nil
+	if device.modified.Swap(false) || len(updatedModules) > 0 || len(removedModules) > 0 || len(routes) > 0 || len(clearedRoutes) > 0 {
 		return &DeviceUpdate{
-			Id:      device.Id,
-			Type:    device.Type,
-			Name:    device.Name,
-			Status:  device.Status,
-			Modules: updatedModules,
+			Id:             device.Id,
+			Type:           device.Type,
+			Name:           device.Name,
+			Status:         device.Status,
+			Modules:        updatedModules,
+			RemovedModules: removedModules,
+			Routes:         routes,
+			ClearedRoutes:  clearedRoutes,
+			Redundancy:     redundancy,
 		}
 	}
 	return nil
 }
 
+// CompleteDeviceUpdate reports device with all of its modules and iolets,
+// e.g. when it is added to a running driver. Pending changes are consumed.
+func CompleteDeviceUpdate(device Device) *DeviceUpdate {
+	updated := device.Updated()
+	update := &DeviceUpdate{
+		Id:      device.GetId(),
+		Type:    device.GetType(),
+		Name:    device.GetName(),
+		Status:  device.GetStatus(),
+		Modules: make([]ModuleUpdate, 0),
+		Routes:  device.GetRoutes(),
+	}
+	if updated != nil {
+		update.Redundancy = updated.Redundancy
+	}
+	for _, module := range device.GetModules() {
+		update.Modules = append(update.Modules, completeModuleUpdate(module))
+	}
+	return update
+}
+
+// deviceSnapshot is the JSON representation of a device. It is filled while
+// holding the read lock so encoding never observes a half-written device.
+// Modules are only set when encoding the complete tree.
+type deviceSnapshot struct {
+	Id                DeviceId                     `json:"deviceId"`
+	Type              DeviceType                   `json:"type"`
+	Name              string                       `json:"name"`
+	Status            DeviceStatus                 `json:"status"`
+	ControlIP         string                       `json:"controlIP,omitempty"`
+	ControlPort       int                          `json:"controlPort,omitempty"`
+	Controls          []DeviceControl              `json:"controls"`
+	ControlParameters map[DeviceControl]Parameters `json:"controlParameters,omitempty"`
+	ModuleTypes       []ModuleType                 `json:"moduleTypes"`
+	// Routing is set when the device has a route action
+	Routing bool             `json:"routing,omitempty"`
+	Modules []moduleSnapshot `json:"modules,omitempty"`
+	Routes  []Route          `json:"routes,omitempty"`
+}
+
+func (device *deviceImpl) snapshot() deviceSnapshot {
+	device.mutex.RLock()
+	defer device.mutex.RUnlock()
+	return deviceSnapshot{
+		Id:                device.Id,
+		Type:              device.Type,
+		Name:              device.Name,
+		Status:            device.Status,
+		ControlIP:         device.ControlIP,
+		ControlPort:       device.ControlPort,
+		Controls:          append([]DeviceControl{}, device.Controls...),
+		ControlParameters: maps.Clone(device.parameters),
+		ModuleTypes:       append([]ModuleType{}, device.ModuleTypes...),
+		Routing:           device.routeAction != nil,
+	}
+}
+
+func deviceControlParameters(device Device) map[DeviceControl]Parameters {
+	parameters := make(map[DeviceControl]Parameters)
+	for _, control := range device.GetControls() {
+		if controlParameters := device.GetParameters(control); len(controlParameters) > 0 {
+			parameters[control] = controlParameters
+		}
+	}
+	return parameters
+}
+
+func (device *deviceImpl) MarshalJSON() ([]byte, error) {
+	return json.Marshal(device.snapshot())
+}
+
+func deviceTreeSnapshot(device Device) deviceSnapshot {
+	var snapshot deviceSnapshot
+	if impl, ok := device.(*deviceImpl); ok {
+		snapshot = impl.snapshot()
+	} else {
+		snapshot = deviceSnapshot{
+			Id:                device.GetId(),
+			Type:              device.GetType(),
+			Name:              device.GetName(),
+			Status:            device.GetStatus(),
+			ControlIP:         device.GetControlIP(),
+			ControlPort:       device.GetControlPort(),
+			Controls:          append([]DeviceControl{}, device.GetControls()...),
+			ControlParameters: deviceControlParameters(device),
+			ModuleTypes:       append([]ModuleType{}, device.GetModuleTypes()...),
+		}
+	}
+	for _, module := range device.GetModules() {
+		snapshot.Modules = append(snapshot.Modules, moduleTreeSnapshot(module))
+	}
+	snapshot.Routes = device.GetRoutes()
+	return snapshot
+}
+
+func (snapshot deviceSnapshot) device() (Device, error) {
+	device := NewDevice(snapshot.Id, snapshot.Type, snapshot.Name).(*deviceImpl)
+	device.Status = snapshot.Status
+	device.ControlIP = snapshot.ControlIP
+	device.ControlPort = snapshot.ControlPort
+	device.Controls = append(device.Controls, snapshot.Controls...)
+	for control, parameters := range snapshot.ControlParameters {
+		device.parameters[control] = parameters
+	}
+	device.ModuleTypes = append(device.ModuleTypes, snapshot.ModuleTypes...)
+	for _, moduleSnapshot := range snapshot.Modules {
+		module, err := moduleSnapshot.module()
+		if err != nil {
+			return nil, err
+		}
+		if err := device.AddModuleChecked(module); err != nil {
+			return nil, err
+		}
+	}
+	device.Routes = append(device.Routes, snapshot.Routes...)
+	return device, nil
+}
+
 type DeviceId string
 
 type DeviceType string
@@ -252,12 +686,23 @@ const (
 	DeviceControl_SHUTDOWN DeviceControl = "SHUTDOWN"
 )
 
+// Valid reports whether the control is registered, see RegisterDeviceControl.
+// Elements may still support controls which are not registered.
 func (control DeviceControl) Valid() error {
-	valid := control == DeviceControl_BOOT || control == DeviceControl_REBOOT || control == DeviceControl_SHUTDOWN
-	if valid {
+	if _, ok := deviceControls.lookup(string(control)); ok {
 		return nil
 	}
 	return fmt.Errorf("%s is not a valid control", control)
 }
 
+// Definition returns the registered label and description of the control.
+func (control DeviceControl) Definition() ControlDefinition {
+	if definition, ok := deviceControls.lookup(string(control)); ok {
+		return definition
+	}
+	return ControlDefinition{Control: string(control), Label: string(control)}
+}
+
 type DeviceAction func(ctx context.Context, device Device) error
+
+type DeviceParameterizedAction func(ctx context.Context, device Device, args Arguments) error
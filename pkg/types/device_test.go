@@ -0,0 +1,337 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestDeviceConcurrentAccess is meant to be run with `go test -race`.
+func TestDeviceConcurrentAccess(t *testing.T) {
+	device := NewDevice("1", DeviceType__GENERIC_DUMMY, "Device")
+	module := NewModule("1", ModuleType_AV, "Module")
+	iolet := NewIOlet("1", IOletType_IPVIDEOIN, "IOlet")
+	module.AddIOlet(iolet)
+	device.AddModule(module)
+	iolet.AddAction(IOletControl_STOP, func(ctx context.Context, iolet IOlet) error { return nil })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				device.SetName(fmt.Sprintf("Device %d-%d", i, j))
+				device.SetStatus(DeviceStatus(j % 2))
+				module.SetStatus(ModuleStatus(j % 2))
+				iolet.SetStatus(IOletStatus(j % 4))
+				iolet.AddAction(IOletControl_START, func(ctx context.Context, iolet IOlet) error { return nil })
+				module.AddIOlet(NewIOlet(IOletId(fmt.Sprintf("%d-%d", i, j)), IOletType_IPAUDIOIN, "Audio"))
+				device.AddModule(NewModule(ModuleId(fmt.Sprintf("%d-%d", i, j)), ModuleType_GPIO, "GPIO"))
+			}
+		}(i)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				device.Updated()
+				if _, err := json.Marshal(device); err != nil {
+					t.Error(err)
+				}
+				if _, err := json.Marshal(module.GetIOlets()); err != nil {
+					t.Error(err)
+				}
+				device.GetModulesByType(ModuleType_GPIO)
+				module.GetIOlet("1")
+				if err := iolet.FireAction(context.Background(), IOletControl_STOP); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(device.GetModules()); got != 401 {
+		t.Errorf("expected 401 modules, got %d", got)
+	}
+	if got := len(module.GetIOlets()); got != 401 {
+		t.Errorf("expected 401 iolets, got %d", got)
+	}
+}
+
+func TestDeviceModifyStatus(t *testing.T) {
+	device := NewDevice("1", DeviceType__GENERIC_DUMMY, "Device")
+	iolet := NewIOlet("1", IOletType_IPVIDEOIN, "IOlet")
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			iolet.ModifyStatus(func(status *IOletStatus) { status.SetRunning(i%2 == 0) })
+			device.ModifyStatus(func(status *DeviceStatus) { status.SetONLINE(true) })
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			iolet.ModifyStatus(func(status *IOletStatus) { status.SetSending(true) })
+		}
+	}()
+	wg.Wait()
+
+	if !iolet.GetStatus().Sending() {
+		t.Error("iolet status should be Sending")
+	}
+	if !device.GetStatus().ONLINE() {
+		t.Error("device status should be ONLINE")
+	}
+}
+
+func TestDeviceUpdated(t *testing.T) {
+	device := NewDevice("1", DeviceType__GENERIC_DUMMY, "Device")
+	module := NewModule("1", ModuleType_AV, "Module")
+	iolet := NewIOlet("1", IOletType_IPVIDEOIN, "IOlet")
+	module.AddIOlet(iolet)
+	device.AddModule(module)
+
+	if device.Updated() != nil {
+		t.Error("new device should not be updated")
+	}
+
+	iolet.ModifyStatus(func(status *IOletStatus) { status.SetRunning(true) })
+	update := device.Updated()
+	if update == nil {
+		t.Fatal("device should be updated")
+	}
+	if len(update.Modules) != 1 || len(update.Modules[0].IOlets) != 1 {
+		t.Fatal("update should contain the modified iolet")
+	}
+	if !update.Modules[0].IOlets[0].Status.Running() {
+		t.Error("iolet update should be Running")
+	}
+	if device.Updated() != nil {
+		t.Error("device should not be updated twice")
+	}
+
+	module.SetName("Renamed")
+	update = device.Updated()
+	if update == nil || len(update.Modules) != 1 || update.Modules[0].Name != "Renamed" {
+		t.Error("module rename should be reported")
+	}
+}
+
+func TestDeviceTreeJSON(t *testing.T) {
+	device := NewDevice("1", DeviceType__GENERIC_DUMMY, "Device")
+	device.SetControlIP("10.0.0.1")
+	device.SetControlPort(8080)
+	device.SetStatus(DeviceStatus_ONLINE)
+	device.AddAction(DeviceControl_REBOOT, func(ctx context.Context, device Device) error { return nil })
+	module := NewModule("1", ModuleType_AV, "Module")
+	module.AddAction(ModuleControl_START, func(ctx context.Context, module Module) error { return nil })
+	iolet := NewIOlet("1", IOletType_IPVIDEOIN, "IOlet")
+	iolet.AddAction(IOletControl_STOP, func(ctx context.Context, iolet IOlet) error { return nil })
+	iolet.SetStatus(IOletStatus_RUNNING)
+	module.AddIOlet(iolet)
+	module.AddIOlet(NewIOlet("2", IOletType_IPAUDIOOUT, "Audio"))
+	device.AddModule(module)
+	device.AddModule(NewModule("2", ModuleType_POWER, "Power"))
+
+	var buffer bytes.Buffer
+	if err := DevicesToJSON(json.NewEncoder(&buffer), []Device{device}); err != nil {
+		t.Fatal(err)
+	}
+	devices, err := DevicesFromJSON(json.NewDecoder(&buffer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 {
+		t.Fatalf("expected 1 device, got %d", len(devices))
+	}
+
+	decoded := devices[0]
+	if decoded.GetId() != "1" || decoded.GetType() != DeviceType__GENERIC_DUMMY || decoded.GetName() != "Device" {
+		t.Error("device fields not restored")
+	}
+	if decoded.GetControlIP() != "10.0.0.1" || decoded.GetControlPort() != 8080 || !decoded.GetStatus().ONLINE() {
+		t.Error("device connection or status not restored")
+	}
+	if controls := decoded.GetControls(); len(controls) != 1 || controls[0] != DeviceControl_REBOOT {
+		t.Errorf("device controls not restored: %v", controls)
+	}
+	if moduleTypes := decoded.GetModuleTypes(); len(moduleTypes) != 2 {
+		t.Errorf("expected 2 module types, got %v", moduleTypes)
+	}
+	if len(decoded.GetModules()) != 2 {
+		t.Fatalf("expected 2 modules, got %d", len(decoded.GetModules()))
+	}
+
+	decodedModule := decoded.GetModule("1")
+	if decodedModule == nil || decodedModule.GetName() != "Module" || len(decodedModule.GetControls()) != 1 {
+		t.Fatal("module not restored")
+	}
+	if len(decodedModule.GetIOlets()) != 2 || len(decodedModule.GetIOletTypes()) != 2 {
+		t.Fatal("iolets not restored")
+	}
+	decodedIOlet := decodedModule.GetIOlet("1")
+	if decodedIOlet.GetType() != IOletType_IPVIDEOIN || !decodedIOlet.GetStatus().Running() {
+		t.Error("iolet fields not restored")
+	}
+	if controls := decodedIOlet.GetControls(); len(controls) != 1 || controls[0] != IOletControl_STOP {
+		t.Errorf("iolet controls not restored: %v", controls)
+	}
+	if decoded.Updated() != nil {
+		t.Error("decoded device should not be updated")
+	}
+
+	// re-encoding the decoded tree must be lossless
+	var first, second bytes.Buffer
+	if err := DeviceToJSON(json.NewEncoder(&first), device); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeviceToJSON(json.NewEncoder(&second), decoded); err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != second.String() {
+		t.Errorf("round trip differs:\n%s\n%s", first.String(), second.String())
+	}
+}
+
+func TestDeviceJSONWithoutModules(t *testing.T) {
+	device := NewDevice("1", DeviceType__GENERIC_DUMMY, "Device")
+	device.AddModule(NewModule("1", ModuleType_AV, "Module"))
+
+	body, err := json.Marshal(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(body, []byte(`"modules"`)) {
+		t.Errorf("plain device JSON should not contain modules: %s", body)
+	}
+
+	decoded, err := DeviceFromJSON(json.NewDecoder(bytes.NewReader(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.GetModules()) != 0 || len(decoded.GetModuleTypes()) != 1 {
+		t.Error("device without modules should keep its module types")
+	}
+}
+
+func TestDeviceJSONDuplicateIds(t *testing.T) {
+	body := `{"id": "1", "type": "GENERIC_DUMMY", "name": "Device", "modules": [
+		{"id": "1", "type": "AV", "name": "Module 1"},
+		{"id": "1", "type": "AV", "name": "Module 2"}
+	]}`
+	if _, err := DeviceFromJSON(json.NewDecoder(strings.NewReader(body))); !errors.Is(err, ErrDuplicateId) {
+		t.Errorf("duplicate module ids should be refused, got %v", err)
+	}
+}
+
+func TestDeviceRemoveModule(t *testing.T) {
+	device := NewDevice("1", DeviceType__GENERIC_DUMMY, "Device")
+	device.AddModule(NewModule("1", ModuleType_AV, "Video"))
+	device.AddModule(NewModule("2", ModuleType_GPIO, "GPIO"))
+
+	if device.RemoveModule("3") != nil {
+		t.Error("removing an unknown module should return nil")
+	}
+	if removed := device.RemoveModule("2"); removed == nil || removed.GetId() != "2" {
+		t.Fatal("module 2 should be removed")
+	}
+	if device.GetModule("2") != nil {
+		t.Error("module 2 should be gone")
+	}
+	if moduleTypes := device.GetModuleTypes(); len(moduleTypes) != 1 || moduleTypes[0] != ModuleType_AV {
+		t.Errorf("module type of removed module should be gone: %v", moduleTypes)
+	}
+
+	update := device.Updated()
+	if update == nil || len(update.RemovedModules) != 1 || update.RemovedModules[0] != "2" {
+		t.Fatal("removal should be reported")
+	}
+	if device.Updated() != nil {
+		t.Error("removal should be reported once")
+	}
+}
+
+func TestDeviceReplaceModule(t *testing.T) {
+	device := NewDevice("1", DeviceType__GENERIC_DUMMY, "Device")
+	old := NewModule("1", ModuleType_AV, "Video")
+	old.AddIOlet(NewIOlet("1", IOletType_IPVIDEOIN, "In"))
+	device.AddModule(old)
+
+	replacement := NewModule("1", ModuleType_AV, "Video")
+	replacement.AddIOlet(NewIOlet("2", IOletType_IPVIDEOOUT, "Out"))
+	if replaced := device.ReplaceModule(replacement); replaced != old {
+		t.Error("replace should return the old module")
+	}
+	if len(device.GetModules()) != 1 || device.GetModule("1") != replacement {
+		t.Fatal("module should be replaced in place")
+	}
+
+	update := device.Updated()
+	if update == nil || len(update.Modules) != 1 {
+		t.Fatal("replacement should be reported")
+	}
+	moduleUpdate := update.Modules[0]
+	if !moduleUpdate.Replaced || len(moduleUpdate.IOlets) != 1 || moduleUpdate.IOlets[0].Id != "2" {
+		t.Errorf("replaced module should be reported completely: %+v", moduleUpdate)
+	}
+
+	device.RemoveModule("1")
+	device.AddModule(old)
+	update = device.Updated()
+	if update == nil || len(update.RemovedModules) != 0 || len(update.Modules) != 1 || !update.Modules[0].Replaced {
+		t.Error("module removed and added again should be reported as replaced")
+	}
+}
+
+func TestDeviceNotifier(t *testing.T) {
+	notifier := NewCoalescingNotifier()
+	device := NewDevice("1", DeviceType__GENERIC_DUMMY, "Device")
+	other := NewDevice("2", DeviceType__GENERIC_DUMMY, "Other")
+	device.SetNotifier(notifier)
+	other.SetNotifier(notifier)
+	module := NewModule("1", ModuleType_AV, "Module")
+	iolet := NewIOlet("1", IOletType_IPVIDEOIN, "IOlet")
+	module.AddIOlet(iolet)
+	device.AddModule(module)
+
+	select {
+	case <-notifier.Signal():
+		t.Fatal("adding elements should not notify")
+	default:
+	}
+
+	iolet.SetStatus(IOletStatus_RUNNING)
+	module.SetName("Renamed")
+	other.SetName("Renamed")
+	device.SetStatus(DeviceStatus_ONLINE)
+
+	select {
+	case <-notifier.Signal():
+	default:
+		t.Fatal("changes should signal")
+	}
+	devices := notifier.Take()
+	if len(devices) != 2 || devices[0] != device || devices[1] != other {
+		t.Fatalf("each changed device should be queued once: %v", devices)
+	}
+
+	device.RemoveModule("1")
+	iolet.SetStatus(0)
+	devices = notifier.Take()
+	if len(devices) != 1 || devices[0] != device {
+		t.Fatal("removal should notify")
+	}
+	if len(notifier.Take()) != 0 {
+		t.Error("iolets of removed modules should not notify")
+	}
+}
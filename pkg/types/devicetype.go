@@ -0,0 +1,105 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// DeviceTypeDefinition describes a device type to the gateway. Drivers
+// register definitions for their own device types, the built-in ones are
+// pre-registered.
+type DeviceTypeDefinition struct {
+	Type        DeviceType `json:"type"`
+	Vendor      string     `json:"vendor,omitempty"`
+	ModelFamily string     `json:"modelFamily,omitempty"`
+	DisplayName string     `json:"displayName"`
+	// ModuleLayout lists the modules a device of this type usually has
+	ModuleLayout []ModuleLayout `json:"moduleLayout,omitempty"`
+	// Controls lists the device controls devices of this type support
+	Controls []DeviceControl `json:"controls,omitempty"`
+}
+
+// ModuleLayout is a module of the default layout of a device type.
+type ModuleLayout struct {
+	Type       ModuleType  `json:"type"`
+	Name       string      `json:"name"`
+	IOletTypes []IOletType `json:"ioletTypes,omitempty"`
+}
+
+type deviceTypeRegistry struct {
+	mutex       sync.RWMutex
+	definitions []DeviceTypeDefinition
+}
+
+func (registry *deviceTypeRegistry) register(definition DeviceTypeDefinition) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	index := slices.IndexFunc(registry.definitions, func(known DeviceTypeDefinition) bool { return known.Type == definition.Type })
+	if index < 0 {
+		registry.definitions = append(registry.definitions, definition)
+		return
+	}
+	registry.definitions[index] = definition
+}
+
+func (registry *deviceTypeRegistry) lookup(deviceType DeviceType) (DeviceTypeDefinition, bool) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	for _, definition := range registry.definitions {
+		if definition.Type == deviceType {
+			return definition, true
+		}
+	}
+	return DeviceTypeDefinition{}, false
+}
+
+func (registry *deviceTypeRegistry) list() []DeviceTypeDefinition {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	return append([]DeviceTypeDefinition(nil), registry.definitions...)
+}
+
+var deviceTypes = &deviceTypeRegistry{definitions: []DeviceTypeDefinition{
+	{Type: DeviceType__GENERIC_DUMMY, Vendor: "Generic", DisplayName: "Dummy"},
+	{
+		Type:         DeviceType_GENERIC_USV,
+		Vendor:       "Generic",
+		DisplayName:  "UPS",
+		ModuleLayout: []ModuleLayout{{Type: ModuleType_POWER, Name: "UPS"}},
+	},
+	{Type: DeviceType_XLINK_XLINK, Vendor: "XLink", DisplayName: "XLink"},
+	{Type: DeviceType_RIEDEL_FUSION, Vendor: "Riedel", ModelFamily: "MediorNet", DisplayName: "FUSION"},
+	{Type: DeviceType_RIEDEL_MUON, Vendor: "Riedel", ModelFamily: "MediorNet", DisplayName: "MuoN"},
+	{Type: DeviceType_RIEDEL_BOLERO, Vendor: "Riedel", ModelFamily: "Bolero", DisplayName: "Bolero"},
+	{Type: DeviceType_RIEDEL_NSA02, Vendor: "Riedel", ModelFamily: "Artist", DisplayName: "NSA-002"},
+	{Type: DeviceType_DIRECTOUT_RAVIO, Vendor: "DirectOut", DisplayName: "RAVIO"},
+}}
+
+// RegisterDeviceType declares a driver specific device type, or replaces the
+// definition of a known one.
+func RegisterDeviceType(definition DeviceTypeDefinition) {
+	deviceTypes.register(definition)
+}
+
+// DeviceTypeDefinitions returns all registered device types.
+func DeviceTypeDefinitions() []DeviceTypeDefinition {
+	return deviceTypes.list()
+}
+
+// Valid reports whether the device type is registered, see
+// RegisterDeviceType.
+func (deviceType DeviceType) Valid() error {
+	if _, ok := deviceTypes.lookup(deviceType); ok {
+		return nil
+	}
+	return fmt.Errorf("%q is not a valid device type", deviceType)
+}
+
+// Definition returns the registered definition of the device type.
+func (deviceType DeviceType) Definition() DeviceTypeDefinition {
+	if definition, ok := deviceTypes.lookup(deviceType); ok {
+		return definition
+	}
+	return DeviceTypeDefinition{Type: deviceType, DisplayName: string(deviceType)}
+}
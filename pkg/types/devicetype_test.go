@@ -0,0 +1,47 @@
+package types
+
+import "testing"
+
+// keepDeviceTypes restores the device type registry when the test ends.
+func keepDeviceTypes(t *testing.T) {
+	definitions := deviceTypes.list()
+	t.Cleanup(func() {
+		deviceTypes.mutex.Lock()
+		defer deviceTypes.mutex.Unlock()
+		deviceTypes.definitions = definitions
+	})
+}
+
+func TestDeviceTypeRegistry(t *testing.T) {
+	keepDeviceTypes(t)
+	if definition := DeviceType_RIEDEL_MUON.Definition(); definition.Vendor != "Riedel" || definition.DisplayName != "MuoN" {
+		t.Errorf("built-in device types should be pre-registered, got %+v", definition)
+	}
+	if err := DeviceType("LAWO_A_UHD_CORE").Valid(); err == nil {
+		t.Error("unregistered device type should not be valid")
+	}
+	if definition := DeviceType("LAWO_A_UHD_CORE").Definition(); definition.DisplayName != "LAWO_A_UHD_CORE" {
+		t.Errorf("unregistered device types should be named by their type, got %+v", definition)
+	}
+
+	count := len(DeviceTypeDefinitions())
+	RegisterDeviceType(DeviceTypeDefinition{
+		Type:         "LAWO_A_UHD_CORE",
+		Vendor:       "Lawo",
+		ModelFamily:  "A__UHD",
+		DisplayName:  "A__UHD Core",
+		ModuleLayout: []ModuleLayout{{Type: ModuleType_AV, Name: "DSP", IOletTypes: []IOletType{IOletType_IPAUDIOIN, IOletType_IPAUDIOOUT}}},
+		Controls:     []DeviceControl{DeviceControl_REBOOT},
+	})
+	if err := DeviceType("LAWO_A_UHD_CORE").Valid(); err != nil {
+		t.Error(err)
+	}
+	if len(DeviceTypeDefinitions()) != count+1 {
+		t.Errorf("expected %d device types, got %d", count+1, len(DeviceTypeDefinitions()))
+	}
+
+	RegisterDeviceType(DeviceTypeDefinition{Type: "LAWO_A_UHD_CORE", Vendor: "Lawo", DisplayName: "A__UHD Core 2"})
+	if definition := DeviceType("LAWO_A_UHD_CORE").Definition(); definition.DisplayName != "A__UHD Core 2" || len(DeviceTypeDefinitions()) != count+1 {
+		t.Errorf("registering a known device type should replace its definition, got %+v", definition)
+	}
+}
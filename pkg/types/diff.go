@@ -0,0 +1,186 @@
+package types
+
+import (
+	"reflect"
+)
+
+// FieldChange is a changed field of a device, module or iolet. Field is the
+// JSON name of the field.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// TreeDiff lists the differences between two states of the device tree.
+// Added elements are listed by id only, their content is found in the new
+// tree.
+type TreeDiff struct {
+	AddedDevices   []DeviceId   `json:"addedDevices,omitempty"`
+	RemovedDevices []DeviceId   `json:"removedDevices,omitempty"`
+	ChangedDevices []DeviceDiff `json:"changedDevices,omitempty"`
+}
+
+type DeviceDiff struct {
+	Id             DeviceId      `json:"deviceId"`
+	Changes        []FieldChange `json:"changes,omitempty"`
+	AddedModules   []ModuleId    `json:"addedModules,omitempty"`
+	RemovedModules []ModuleId    `json:"removedModules,omitempty"`
+	ChangedModules []ModuleDiff  `json:"changedModules,omitempty"`
+}
+
+type ModuleDiff struct {
+	Id            ModuleId      `json:"moduleId"`
+	Changes       []FieldChange `json:"changes,omitempty"`
+	AddedIOlets   []IOletId     `json:"addedIOlets,omitempty"`
+	RemovedIOlets []IOletId     `json:"removedIOlets,omitempty"`
+	ChangedIOlets []IOletDiff   `json:"changedIOlets,omitempty"`
+}
+
+type IOletDiff struct {
+	Id      IOletId       `json:"ioletId"`
+	Changes []FieldChange `json:"changes"`
+}
+
+func (diff TreeDiff) Empty() bool {
+	return len(diff.AddedDevices) == 0 && len(diff.RemovedDevices) == 0 && len(diff.ChangedDevices) == 0
+}
+
+// DiffDevices compares two states of the device tree, e.g. the devices
+// decoded by DevicesFromJSON before a reconnect and the devices after it.
+// Elements are matched by id, iolets by id within their module.
+func DiffDevices(before []Device, after []Device) TreeDiff {
+	beforeSnapshots := make([]deviceSnapshot, 0, len(before))
+	for _, device := range before {
+		beforeSnapshots = append(beforeSnapshots, deviceTreeSnapshot(device))
+	}
+	afterSnapshots := make([]deviceSnapshot, 0, len(after))
+	for _, device := range after {
+		afterSnapshots = append(afterSnapshots, deviceTreeSnapshot(device))
+	}
+
+	var diff TreeDiff
+	diff.AddedDevices, diff.RemovedDevices = diffIds(beforeSnapshots, afterSnapshots, func(snapshot deviceSnapshot) DeviceId { return snapshot.Id })
+	for _, afterSnapshot := range afterSnapshots {
+		for _, beforeSnapshot := range beforeSnapshots {
+			if beforeSnapshot.Id == afterSnapshot.Id {
+				if deviceDiff := diffDevice(beforeSnapshot, afterSnapshot); deviceDiff != nil {
+					diff.ChangedDevices = append(diff.ChangedDevices, *deviceDiff)
+				}
+				break
+			}
+		}
+	}
+	return diff
+}
+
+// DiffDevice compares two states of a device including its modules and
+// iolets, and returns nil if they are equal.
+func DiffDevice(before Device, after Device) *DeviceDiff {
+	return diffDevice(deviceTreeSnapshot(before), deviceTreeSnapshot(after))
+}
+
+func diffDevice(before deviceSnapshot, after deviceSnapshot) *DeviceDiff {
+	diff := DeviceDiff{Id: after.Id}
+	diffField(&diff.Changes, "deviceId", before.Id, after.Id)
+	diffField(&diff.Changes, "type", before.Type, after.Type)
+	diffField(&diff.Changes, "name", before.Name, after.Name)
+	diffField(&diff.Changes, "status", before.Status, after.Status)
+	diffField(&diff.Changes, "controlIP", before.ControlIP, after.ControlIP)
+	diffField(&diff.Changes, "controlPort", before.ControlPort, after.ControlPort)
+	diffField(&diff.Changes, "controls", before.Controls, after.Controls)
+	diffField(&diff.Changes, "moduleTypes", before.ModuleTypes, after.ModuleTypes)
+	diffField(&diff.Changes, "routes", before.Routes, after.Routes)
+
+	diff.AddedModules, diff.RemovedModules = diffIds(before.Modules, after.Modules, func(snapshot moduleSnapshot) ModuleId { return snapshot.Id })
+	for _, afterModule := range after.Modules {
+		for _, beforeModule := range before.Modules {
+			if beforeModule.Id == afterModule.Id {
+				if moduleDiff := diffModule(beforeModule, afterModule); moduleDiff != nil {
+					diff.ChangedModules = append(diff.ChangedModules, *moduleDiff)
+				}
+				break
+			}
+		}
+	}
+
+	if len(diff.Changes) == 0 && len(diff.AddedModules) == 0 && len(diff.RemovedModules) == 0 && len(diff.ChangedModules) == 0 {
+		return nil
+	}
+	return &diff
+}
+
+func diffModule(before moduleSnapshot, after moduleSnapshot) *ModuleDiff {
+	diff := ModuleDiff{Id: after.Id}
+	diffField(&diff.Changes, "type", before.Type, after.Type)
+	diffField(&diff.Changes, "name", before.Name, after.Name)
+	diffField(&diff.Changes, "status", before.Status, after.Status)
+	diffField(&diff.Changes, "controls", before.Controls, after.Controls)
+	diffField(&diff.Changes, "ioletTypes", before.IOletTypes, after.IOletTypes)
+	diffField(&diff.Changes, "power", before.Power, after.Power)
+
+	diff.AddedIOlets, diff.RemovedIOlets = diffIds(before.IOlets, after.IOlets, func(snapshot ioletSnapshot) IOletId { return snapshot.Id })
+	for _, afterIOlet := range after.IOlets {
+		for _, beforeIOlet := range before.IOlets {
+			if beforeIOlet.Id == afterIOlet.Id {
+				if ioletDiff := diffIOlet(beforeIOlet, afterIOlet); ioletDiff != nil {
+					diff.ChangedIOlets = append(diff.ChangedIOlets, *ioletDiff)
+				}
+				break
+			}
+		}
+	}
+
+	if len(diff.Changes) == 0 && len(diff.AddedIOlets) == 0 && len(diff.RemovedIOlets) == 0 && len(diff.ChangedIOlets) == 0 {
+		return nil
+	}
+	return &diff
+}
+
+func diffIOlet(before ioletSnapshot, after ioletSnapshot) *IOletDiff {
+	diff := IOletDiff{Id: after.Id}
+	diffField(&diff.Changes, "type", before.Type, after.Type)
+	diffField(&diff.Changes, "name", before.Name, after.Name)
+	diffField(&diff.Changes, "status", before.Status, after.Status)
+	diffField(&diff.Changes, "controls", before.Controls, after.Controls)
+	diffField(&diff.Changes, "stream", before.Stream, after.Stream)
+	diffField(&diff.Changes, "ptp", before.PTP, after.PTP)
+
+	if len(diff.Changes) == 0 {
+		return nil
+	}
+	return &diff
+}
+
+// diffField appends a change if before and after differ. Nil and empty slices
+// are equal.
+func diffField(changes *[]FieldChange, field string, before any, after any) {
+	beforeValue, afterValue := reflect.ValueOf(before), reflect.ValueOf(after)
+	if beforeValue.Kind() == reflect.Slice && beforeValue.Len() == 0 && afterValue.Len() == 0 {
+		return
+	}
+	if !reflect.DeepEqual(before, after) {
+		*changes = append(*changes, FieldChange{Field: field, Old: before, New: after})
+	}
+}
+
+// diffIds returns the ids only found in after and the ids only found in before.
+func diffIds[T any, Id comparable](before []T, after []T, id func(T) Id) (added []Id, removed []Id) {
+	beforeIds := make(map[Id]bool)
+	for _, element := range before {
+		beforeIds[id(element)] = true
+	}
+	afterIds := make(map[Id]bool)
+	for _, element := range after {
+		afterIds[id(element)] = true
+		if !beforeIds[id(element)] {
+			added = append(added, id(element))
+		}
+	}
+	for _, element := range before {
+		if !afterIds[id(element)] {
+			removed = append(removed, id(element))
+		}
+	}
+	return added, removed
+}
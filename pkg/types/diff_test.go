@@ -0,0 +1,68 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func diffTestTree() []Device {
+	device := NewDevice("1", DeviceType__GENERIC_DUMMY, "Encoder")
+	device.SetStatus(DeviceStatus_ONLINE)
+	module := NewModule("1", ModuleType_AV, "Channel 1")
+	module.AddIOlet(NewIOlet("1", IOletType_IPVIDEOIN, "Video In"))
+	module.AddIOlet(NewIOlet("2", IOletType_IPVIDEOOUT, "Video Out"))
+	device.AddModule(module)
+	return []Device{device, NewDevice("2", DeviceType__GENERIC_DUMMY, "Decoder")}
+}
+
+func TestDiffDevices(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := DevicesToJSON(json.NewEncoder(&buffer), diffTestTree()); err != nil {
+		t.Fatal(err)
+	}
+	before, err := DevicesFromJSON(json.NewDecoder(&buffer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := diffTestTree()
+	if diff := DiffDevices(before, after); !diff.Empty() {
+		t.Fatalf("decoded tree should equal the original, got %+v", diff)
+	}
+
+	after[0].SetName("Encoder 1")
+	module := after[0].GetModule("1")
+	module.RemoveIOlet("2")
+	module.AddIOlet(NewIOlet("3", IOletType_IPAUDIOOUT, "Audio Out"))
+	module.GetIOlet("1").SetStatus(IOletStatus_NOK)
+	after = append(after[:1], NewDevice("3", DeviceType__GENERIC_DUMMY, "Multiviewer"))
+
+	diff := DiffDevices(before, after)
+	if len(diff.AddedDevices) != 1 || diff.AddedDevices[0] != "3" || len(diff.RemovedDevices) != 1 || diff.RemovedDevices[0] != "2" {
+		t.Errorf("unexpected added %v and removed %v devices", diff.AddedDevices, diff.RemovedDevices)
+	}
+	if len(diff.ChangedDevices) != 1 {
+		t.Fatalf("expected 1 changed device, got %+v", diff.ChangedDevices)
+	}
+	deviceDiff := diff.ChangedDevices[0]
+	if len(deviceDiff.Changes) != 1 || deviceDiff.Changes[0] != (FieldChange{Field: "name", Old: "Encoder", New: "Encoder 1"}) {
+		t.Errorf("unexpected device changes %+v", deviceDiff.Changes)
+	}
+	if len(deviceDiff.ChangedModules) != 1 {
+		t.Fatalf("expected 1 changed module, got %+v", deviceDiff.ChangedModules)
+	}
+	moduleDiff := deviceDiff.ChangedModules[0]
+	if len(moduleDiff.AddedIOlets) != 1 || moduleDiff.AddedIOlets[0] != "3" || len(moduleDiff.RemovedIOlets) != 1 || moduleDiff.RemovedIOlets[0] != "2" {
+		t.Errorf("unexpected added %v and removed %v iolets", moduleDiff.AddedIOlets, moduleDiff.RemovedIOlets)
+	}
+	if len(moduleDiff.Changes) != 1 || moduleDiff.Changes[0].Field != "ioletTypes" {
+		t.Errorf("unexpected module changes %+v", moduleDiff.Changes)
+	}
+	if len(moduleDiff.ChangedIOlets) != 1 || moduleDiff.ChangedIOlets[0].Changes[0] != (FieldChange{Field: "status", Old: IOletStatus(0), New: IOletStatus(IOletStatus_NOK)}) {
+		t.Errorf("unexpected iolet changes %+v", moduleDiff.ChangedIOlets)
+	}
+
+	if DiffDevice(after[0], after[0]) != nil {
+		t.Error("device should equal itself")
+	}
+}
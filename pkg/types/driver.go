@@ -1,7 +1,93 @@
 package types
 
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+const (
+	// SDK_VERSION is the version of this SDK reported on connect
+	SDK_VERSION = "0.9.0"
+	// PROTOCOL_VERSION is the version of the driver api between driver and
+	// gateway. It is raised on incompatible changes.
+	PROTOCOL_VERSION = 2
+)
+
+// Capability is a feature of the driver api a driver or gateway supports.
+type Capability string
+
+const (
+	Capability_PARAMETERIZED_CONTROLS Capability = "parameterized-controls"
+	Capability_ROUTING                Capability = "routing"
+	Capability_STREAMING_UPDATES      Capability = "streaming-updates"
+	Capability_SDP                    Capability = "sdp"
+	Capability_TALLY                  Capability = "tally"
+	Capability_REDUNDANCY             Capability = "redundancy"
+	Capability_BULK_CONTROLS          Capability = "bulk-controls"
+	Capability_JOBS                   Capability = "jobs"
+)
+
+// BuildInfo identifies the build of a driver.
+type BuildInfo struct {
+	Module    string `json:"module,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Revision  string `json:"revision,omitempty"`
+	GoVersion string `json:"goVersion,omitempty"`
+}
+
 type Driver struct {
 	DeviceType DeviceType `json:"deviceType"`
 	Port       int        `json:"port"`
 	Location   string     `json:"location"`
+	// BaseURL is where the gateway reaches the driver api, empty if the
+	// gateway should use the address the driver connected from
+	BaseURL         string       `json:"baseURL,omitempty"`
+	SDKVersion      string       `json:"sdkVersion,omitempty"`
+	ProtocolVersion int          `json:"protocolVersion,omitempty"`
+	Build           *BuildInfo   `json:"build,omitempty"`
+	Capabilities    []Capability `json:"capabilities,omitempty"`
+	// DeviceTypeDefinition describes DeviceType, see RegisterDeviceType
+	DeviceTypeDefinition *DeviceTypeDefinition `json:"deviceTypeDefinition,omitempty"`
+	// control vocabulary of the driver, see RegisterDeviceControl
+	DeviceControls []ControlDefinition `json:"deviceControls,omitempty"`
+	ModuleControls []ControlDefinition `json:"moduleControls,omitempty"`
+	IOletControls  []ControlDefinition `json:"ioletControls,omitempty"`
+}
+
+// ErrIncompatibleGateway is returned when the gateway does not speak the
+// protocol version of the driver.
+var ErrIncompatibleGateway = errors.New("incompatible gateway")
+
+// GatewayInfo is the answer of the gateway to a connecting driver. Gateways
+// which predate the handshake answer without body, which leaves it empty.
+type GatewayInfo struct {
+	Version string `json:"version,omitempty"`
+	// ProtocolVersion is the newest, MinProtocolVersion the oldest protocol
+	// version the gateway accepts
+	ProtocolVersion    int          `json:"protocolVersion,omitempty"`
+	MinProtocolVersion int          `json:"minProtocolVersion,omitempty"`
+	Capabilities       []Capability `json:"capabilities,omitempty"`
+}
+
+func (info GatewayInfo) Supports(capability Capability) bool {
+	return slices.Contains(info.Capabilities, capability)
+}
+
+// HasHandshake reports whether the gateway answered with its protocol version.
+func (info GatewayInfo) HasHandshake() bool {
+	return info.ProtocolVersion > 0
+}
+
+// Compatible reports whether the gateway accepts PROTOCOL_VERSION. Gateways
+// without handshake are assumed to be compatible, so that drivers keep
+// working with gateways which predate it.
+func (info GatewayInfo) Compatible() error {
+	if info.MinProtocolVersion > PROTOCOL_VERSION {
+		return fmt.Errorf("%w: gateway %s requires protocol version %d, driver speaks %d", ErrIncompatibleGateway, info.Version, info.MinProtocolVersion, PROTOCOL_VERSION)
+	}
+	if info.ProtocolVersion > 0 && info.ProtocolVersion < PROTOCOL_VERSION {
+		return fmt.Errorf("%w: gateway %s speaks protocol version %d, driver requires %d", ErrIncompatibleGateway, info.Version, info.ProtocolVersion, PROTOCOL_VERSION)
+	}
+	return nil
 }
@@ -0,0 +1,38 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestGatewayInfo(t *testing.T) {
+	var legacy GatewayInfo
+	if err := legacy.Compatible(); err != nil {
+		t.Errorf("gateway without handshake should be compatible, got %v", err)
+	}
+	if legacy.HasHandshake() {
+		t.Error("empty answer should have no handshake")
+	}
+
+	var info GatewayInfo
+	body := `{"version":"3.1.0","protocolVersion":3,"minProtocolVersion":2,"capabilities":["routing","sdp"]}`
+	if err := json.Unmarshal([]byte(body), &info); err != nil {
+		t.Fatal(err)
+	}
+	if err := info.Compatible(); err != nil || !info.HasHandshake() {
+		t.Errorf("gateway should have a compatible handshake, got %v", err)
+	}
+	if !info.Supports(Capability_SDP) || info.Supports(Capability_TALLY) {
+		t.Errorf("unexpected capabilities %v", info.Capabilities)
+	}
+
+	newer := GatewayInfo{Version: "4.0.0", ProtocolVersion: PROTOCOL_VERSION + 1, MinProtocolVersion: PROTOCOL_VERSION + 1}
+	if err := newer.Compatible(); !errors.Is(err, ErrIncompatibleGateway) {
+		t.Errorf("gateway requiring a newer protocol should be incompatible, got %v", err)
+	}
+	older := GatewayInfo{Version: "1.0.0", ProtocolVersion: PROTOCOL_VERSION - 1}
+	if err := older.Compatible(); !errors.Is(err, ErrIncompatibleGateway) {
+		t.Errorf("gateway speaking an older protocol should be incompatible, got %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+package types
+
+import "errors"
+
+// Errors returned when looking up elements by their path, wrapped with the
+// missing id.
+var (
+	ErrDeviceNotFound = errors.New("device not found")
+	ErrModuleNotFound = errors.New("module not found")
+	ErrIOletNotFound  = errors.New("iolet not found")
+	// ErrTypeMismatch is returned when an element exists but has another
+	// type than its path says.
+	ErrTypeMismatch = errors.New("type mismatch")
+	// ErrDuplicateId is returned when adding an element whose id is already
+	// taken by a sibling, see ModuleAdder and IOletAdder.
+	ErrDuplicateId = errors.New("duplicate id")
+)
@@ -0,0 +1,158 @@
+package types
+
+import (
+	"context"
+	"time"
+)
+
+// maxGPIOEvents bounds the edges kept between two updates. Older edges are
+// dropped, the level itself is always reported by the status.
+const maxGPIOEvents = 64
+
+type Edge string
+
+const (
+	Edge_RISING  Edge = "rising"
+	Edge_FALLING Edge = "falling"
+)
+
+// GPIOEvent is a level change of a GPIO iolet.
+type GPIOEvent struct {
+	Edge Edge      `json:"edge"`
+	Time time.Time `json:"time"`
+}
+
+// GPIO reports whether iolets of this type are general purpose inputs or
+// outputs, whose level is the HIGH status flag.
+func (ioletType IOletType) GPIO() bool {
+	return ioletType.GPI() || ioletType.GPO()
+}
+
+func (ioletType IOletType) GPI() bool {
+	return ioletType == IOletType_IPGPIO || ioletType == IOletType_BBGPIO
+}
+
+func (ioletType IOletType) GPO() bool {
+	return ioletType == IOletType_IPGPO || ioletType == IOletType_BBGPO
+}
+
+const (
+	// IOletControl_SET latches a GPO to the level argument.
+	IOletControl_SET IOletControl = "SET"
+	// IOletControl_PULSE inverts a GPO for the duration argument in
+	// milliseconds.
+	IOletControl_PULSE  IOletControl = "PULSE"
+	IOletControl_TOGGLE IOletControl = "TOGGLE"
+)
+
+// GPOOutput drives the output of a GPO iolet on the device.
+type GPOOutput func(ctx context.Context, iolet IOlet, high bool) error
+
+// AddGPOActions adds the SET, PULSE and TOGGLE controls to a GPO iolet. The
+// level of the iolet follows every successful output.
+func AddGPOActions(iolet IOlet, output GPOOutput) {
+	set := func(ctx context.Context, iolet IOlet, high bool) error {
+		if err := output(ctx, iolet, high); err != nil {
+			return err
+		}
+		if reporter, ok := iolet.(LevelReporter); ok {
+			reporter.SetLevel(high, time.Now())
+		} else {
+			iolet.ModifyStatus(func(status *IOletStatus) {
+				status.SetHigh(high)
+			})
+		}
+		return nil
+	}
+
+	iolet.AddParameterizedAction(IOletControl_SET, Parameters{NewBoolParameter("level")}, func(ctx context.Context, iolet IOlet, args Arguments) error {
+		return set(ctx, iolet, args.Bool("level"))
+	})
+	iolet.AddAction(IOletControl_TOGGLE, func(ctx context.Context, iolet IOlet) error {
+		return set(ctx, iolet, !iolet.GetStatus().High())
+	})
+	iolet.AddParameterizedAction(IOletControl_PULSE, Parameters{NewNumberParameter("duration", "ms", 1, 60000)}, func(ctx context.Context, iolet IOlet, args Arguments) error {
+		idle := iolet.GetStatus().High()
+		if err := set(ctx, iolet, !idle); err != nil {
+			return err
+		}
+		timer := time.NewTimer(time.Duration(args.Number("duration") * float64(time.Millisecond)))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		// the output is restored even if the caller gave up waiting
+		return set(context.WithoutCancel(ctx), iolet, idle)
+	})
+}
+
+// Tally is the level of a GPIO iolet of a device.
+type Tally struct {
+	Address IOletAddress `json:"address"`
+	Type    IOletType    `json:"type"`
+	Name    string       `json:"name"`
+	High    bool         `json:"high"`
+	Changed time.Time    `json:"changed"`
+}
+
+// DeviceTally returns the levels of all GPIO iolets of device.
+func DeviceTally(device Device) []Tally {
+	tally := make([]Tally, 0)
+	for _, module := range device.GetModules() {
+		for _, iolet := range module.GetIOlets() {
+			if !iolet.GetType().GPIO() {
+				continue
+			}
+			high, changed := iolet.GetStatus().High(), time.Time{}
+			if reporter, ok := iolet.(LevelReporter); ok {
+				high, changed = reporter.GetLevel()
+			}
+			tally = append(tally, Tally{
+				Address: IOletAddress{DeviceId: device.GetId(), ModuleId: module.GetId(), IOletId: iolet.GetId()},
+				Type:    iolet.GetType(),
+				Name:    iolet.GetName(),
+				High:    high,
+				Changed: changed,
+			})
+		}
+	}
+	return tally
+}
+
+// LevelReporter is implemented by iolets which record the edges of their
+// level, like those of NewIOlet. Other GPIO iolets only keep the HIGH flag.
+type LevelReporter interface {
+	// SetLevel sets the HIGH flag of a GPIO iolet, recording an edge event
+	// at the given time if the level changed.
+	SetLevel(high bool, at time.Time)
+	// GetLevel returns the level of a GPIO iolet and when it last changed.
+	GetLevel() (high bool, changed time.Time)
+}
+
+func (iolet *ioletImpl) SetLevel(high bool, at time.Time) {
+	iolet.mutex.Lock()
+	defer iolet.mutex.Unlock()
+	newStatus := iolet.Status
+	newStatus.SetHigh(high)
+	iolet.applyStatus(newStatus, at)
+}
+
+func (iolet *ioletImpl) GetLevel() (bool, time.Time) {
+	iolet.mutex.RLock()
+	defer iolet.mutex.RUnlock()
+	return iolet.Status.High(), iolet.changed
+}
+
+// recordEdge expects the caller to hold the write lock.
+func (iolet *ioletImpl) recordEdge(high bool, at time.Time) {
+	edge := Edge_FALLING
+	if high {
+		edge = Edge_RISING
+	}
+	if len(iolet.events) == maxGPIOEvents {
+		iolet.events = iolet.events[1:]
+	}
+	iolet.events = append(iolet.events, GPIOEvent{Edge: edge, Time: at})
+	iolet.changed = at
+}
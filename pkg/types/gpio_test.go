@@ -0,0 +1,125 @@
+package types
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGPIEdges(t *testing.T) {
+	gpi := NewIOlet("1", IOletType_BBGPIO, "GPI 1").(*ioletImpl)
+	gpi.Updated()
+
+	start := time.Date(2024, 3, 29, 12, 0, 0, 0, time.UTC)
+	gpi.SetLevel(true, start)
+	gpi.SetLevel(true, start.Add(time.Millisecond))
+	gpi.SetLevel(false, start.Add(2*time.Millisecond))
+	gpi.ModifyStatus(func(status *IOletStatus) { status.SetHigh(true) })
+
+	update := gpi.Updated()
+	if update == nil || len(update.Events) != 3 {
+		t.Fatalf("expected 3 edges, got %+v", update)
+	}
+	if update.Events[0].Edge != Edge_RISING || !update.Events[0].Time.Equal(start) {
+		t.Errorf("unexpected first edge %+v", update.Events[0])
+	}
+	if update.Events[1].Edge != Edge_FALLING || update.Events[2].Edge != Edge_RISING {
+		t.Errorf("unexpected edges %+v", update.Events)
+	}
+	if high, changed := gpi.GetLevel(); !high || !changed.Equal(update.Events[2].Time) {
+		t.Errorf("unexpected level %t changed %s", high, changed)
+	}
+
+	gpi.SetStatus(IOletStatus_HIGH | IOletStatus_NOK)
+	if update := gpi.Updated(); update == nil || len(update.Events) != 0 {
+		t.Errorf("status change without level change should not record an edge, got %+v", update)
+	}
+
+	for i := 0; i < maxGPIOEvents+10; i++ {
+		gpi.SetLevel(i%2 == 0, start.Add(time.Duration(i)*time.Second))
+	}
+	if update := gpi.Updated(); len(update.Events) != maxGPIOEvents || !update.Events[maxGPIOEvents-1].Time.Equal(start.Add((maxGPIOEvents+9)*time.Second)) {
+		t.Errorf("expected the latest %d edges, got %d", maxGPIOEvents, len(update.Events))
+	}
+
+	video := NewIOlet("2", IOletType_IPVIDEOIN, "Video In")
+	video.SetStatus(IOletStatus_HIGH)
+	if update := video.Updated(); len(update.Events) != 0 {
+		t.Error("non GPIO iolets should not record edges")
+	}
+}
+
+func TestGPOActions(t *testing.T) {
+	gpo := NewIOlet("1", IOletType_BBGPO, "GPO 1")
+	var outputs []bool
+	AddGPOActions(gpo, func(ctx context.Context, iolet IOlet, high bool) error {
+		outputs = append(outputs, high)
+		return nil
+	})
+
+	if err := gpo.FireActionWithArguments(context.Background(), IOletControl_SET, Arguments{"level": true}); err != nil {
+		t.Fatal(err)
+	}
+	if !gpo.GetStatus().High() {
+		t.Error("set output should be high")
+	}
+	if err := gpo.FireAction(context.Background(), IOletControl_TOGGLE); err != nil {
+		t.Fatal(err)
+	}
+	if gpo.GetStatus().High() {
+		t.Error("toggled output should be low")
+	}
+	if err := gpo.FireActionWithArguments(context.Background(), IOletControl_PULSE, Arguments{"duration": 5}); err != nil {
+		t.Fatal(err)
+	}
+	if gpo.GetStatus().High() {
+		t.Error("pulsed output should be low again")
+	}
+	expected := []bool{true, false, true, false}
+	if len(outputs) != len(expected) {
+		t.Fatalf("expected outputs %v, got %v", expected, outputs)
+	}
+	for i := range expected {
+		if outputs[i] != expected[i] {
+			t.Fatalf("expected outputs %v, got %v", expected, outputs)
+		}
+	}
+	if update := gpo.Updated(); update == nil || len(update.Events) != 4 {
+		t.Errorf("every output should be an edge, got %+v", update)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := gpo.FireActionWithArguments(ctx, IOletControl_PULSE, Arguments{"duration": 60000}); err != nil {
+		t.Fatal(err)
+	}
+	if gpo.GetStatus().High() {
+		t.Error("cancelled pulse should restore the output")
+	}
+	if err := gpo.FireActionWithArguments(context.Background(), IOletControl_PULSE, Arguments{"duration": 0}); err == nil {
+		t.Error("pulse without duration should be rejected")
+	}
+}
+
+func TestDeviceTally(t *testing.T) {
+	device := NewDevice("1", DeviceType__GENERIC_DUMMY, "Device")
+	module := NewModule("gpio", ModuleType_GPIO, "GPIO")
+	module.AddIOlet(NewIOlet("1", IOletType_BBGPIO, "Red"))
+	module.AddIOlet(NewIOlet("2", IOletType_BBGPO, "Green"))
+	module.AddIOlet(NewIOlet("3", IOletType_BBVIDEOIN, "SDI"))
+	device.AddModule(module)
+
+	changed := time.Date(2024, 3, 29, 12, 0, 0, 0, time.UTC)
+	module.GetIOlet("1").(LevelReporter).SetLevel(true, changed)
+
+	tally := DeviceTally(device)
+	if len(tally) != 2 {
+		t.Fatalf("expected 2 GPIO iolets, got %v", tally)
+	}
+	if tally[0].Address != (IOletAddress{DeviceId: "1", ModuleId: "gpio", IOletId: "1"}) || !tally[0].High || !tally[0].Changed.Equal(changed) {
+		t.Errorf("unexpected tally %+v", tally[0])
+	}
+	if tally[1].High || tally[1].Type != IOletType_BBGPO {
+		t.Errorf("unexpected tally %+v", tally[1])
+	}
+}
@@ -15,3 +15,79 @@ type GroupMember struct {
 	DeviceType DeviceType `json:"devicetype,omitempty"`
 	Group      int64      `json:"group,omitempty"`
 }
+
+type GroupStatus string
+
+const (
+	GroupStatus_OK      GroupStatus = "ok"
+	GroupStatus_WARNING GroupStatus = "warning"
+	GroupStatus_ERROR   GroupStatus = "error"
+)
+
+// GroupHealth is the aggregated state of the modules of a group.
+type GroupHealth struct {
+	Group   int64       `json:"group"`
+	Name    string      `json:"name"`
+	Status  GroupStatus `json:"status"`
+	Members int         `json:"members"`
+	// Offline counts members whose device is offline or unknown
+	Offline int `json:"offline"`
+	// NOK counts members whose module is not OK or unknown
+	NOK int `json:"nok"`
+	// Errors counts the open errors of the member modules and their devices
+	Errors   int              `json:"errors"`
+	Severity PubErrorSeverity `json:"severity"`
+}
+
+// GroupHealthOf rolls the members of group up into one state. A group is in
+// error if a member is offline or has an open error of the highest severity,
+// and in warning if a member is not OK or has any other open error.
+//
+// Errors count for a member if they belong to its module, one of the
+// module's iolets or its device.
+func GroupHealthOf(group Group, members []GroupMember, devices []Device, errors []PubError) GroupHealth {
+	health := GroupHealth{Group: group.Id, Name: group.Name, Status: GroupStatus_OK}
+	type moduleKey struct {
+		deviceId DeviceId
+		moduleId ModuleId
+	}
+	memberModules := make(map[moduleKey]bool)
+	memberDevices := make(map[DeviceId]bool)
+	devicesById := make(map[DeviceId]Device)
+	for _, device := range devices {
+		devicesById[device.GetId()] = device
+	}
+
+	for _, member := range members {
+		if member.Group != 0 && member.Group != group.Id {
+			continue
+		}
+		health.Members++
+		device, ok := devicesById[member.DeviceId]
+		if !ok || !device.GetStatus().ONLINE() {
+			health.Offline++
+		} else if module := device.GetModule(member.ModuleId); module == nil || !module.GetStatus().OK() {
+			health.NOK++
+		}
+		memberModules[moduleKey{member.DeviceId, member.ModuleId}] = true
+		memberDevices[member.DeviceId] = true
+	}
+
+	for _, pubError := range errors {
+		if !memberDevices[pubError.DeviceId] || (pubError.ModuleId != "" && !memberModules[moduleKey{pubError.DeviceId, pubError.ModuleId}]) {
+			continue
+		}
+		if health.Errors == 0 || pubError.Severity > health.Severity {
+			health.Severity = pubError.Severity
+		}
+		health.Errors++
+	}
+
+	switch {
+	case health.Offline > 0 || (health.Errors > 0 && health.Severity >= PubErrorSeverity_HIGHEST):
+		health.Status = GroupStatus_ERROR
+	case health.NOK > 0 || health.Errors > 0:
+		health.Status = GroupStatus_WARNING
+	}
+	return health
+}
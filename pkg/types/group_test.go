@@ -0,0 +1,52 @@
+package types
+
+import "testing"
+
+func TestGroupHealthOf(t *testing.T) {
+	console := NewDevice("1", DeviceType__GENERIC_DUMMY, "Console")
+	console.SetStatus(DeviceStatus_ONLINE)
+	console.AddModule(NewModule("mic", ModuleType_AV, "Mic Inputs"))
+	console.AddModule(NewModule("mix", ModuleType_AV, "Mix Bus"))
+	stagebox := NewDevice("2", DeviceType__GENERIC_DUMMY, "Stagebox")
+	stagebox.SetStatus(DeviceStatus_ONLINE)
+	stagebox.AddModule(NewModule("line", ModuleType_AV, "Line Inputs"))
+	devices := []Device{console, stagebox}
+
+	group := Group{Id: 3, Name: "Studio 3 audio"}
+	members := []GroupMember{
+		{Id: 1, DeviceId: "1", ModuleId: "mic", Group: 3},
+		{Id: 2, DeviceId: "2", ModuleId: "line", Group: 3},
+		{Id: 3, DeviceId: "1", ModuleId: "mix", Group: 4},
+	}
+
+	health := GroupHealthOf(group, members, devices, nil)
+	if health.Status != GroupStatus_OK || health.Members != 2 || health.Name != "Studio 3 audio" {
+		t.Errorf("expected healthy group of 2 members, got %+v", health)
+	}
+
+	errors := []PubError{
+		{DeviceId: "1", ModuleId: "mix", Severity: PubErrorSeverity_HIGHEST},
+		{DeviceId: "1", ModuleId: "mic", IOletId: "1", Severity: PubErrorSeverity_LOWEST},
+	}
+	health = GroupHealthOf(group, members, devices, errors)
+	if health.Status != GroupStatus_WARNING || health.Errors != 1 || health.Severity != PubErrorSeverity_LOWEST {
+		t.Errorf("only errors of member modules should count, got %+v", health)
+	}
+
+	stagebox.GetModule("line").ModifyStatus(func(status *ModuleStatus) { status.SetOK(false) })
+	health = GroupHealthOf(group, members, devices, nil)
+	if health.Status != GroupStatus_WARNING || health.NOK != 1 {
+		t.Errorf("NOK module should be a warning, got %+v", health)
+	}
+
+	errors = append(errors, PubError{DeviceId: "2", Severity: PubErrorSeverity_HIGHEST})
+	health = GroupHealthOf(group, members, devices, errors)
+	if health.Status != GroupStatus_ERROR || health.Errors != 2 || health.Severity != PubErrorSeverity_HIGHEST {
+		t.Errorf("device error of highest severity should be an error, got %+v", health)
+	}
+
+	health = GroupHealthOf(group, members, []Device{console}, nil)
+	if health.Status != GroupStatus_ERROR || health.Offline != 1 {
+		t.Errorf("unknown device should count as offline, got %+v", health)
+	}
+}
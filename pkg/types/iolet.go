@@ -2,8 +2,13 @@ package types
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type IOlet interface {
@@ -13,31 +18,80 @@ type IOlet interface {
 	SetName(newName string)
 	GetStatus() IOletStatus
 	SetStatus(newStatus IOletStatus)
+	// ModifyStatus atomically applies modify to the current status.
+	ModifyStatus(modify func(status *IOletStatus))
 	AddAction(control IOletControl, action IOletAction)
+	// AddParameterizedAction registers an action whose arguments are
+	// validated against parameters before it is called.
+	AddParameterizedAction(control IOletControl, parameters Parameters, action IOletParameterizedAction)
+	// GetParameters returns the argument schema of control, nil if it takes
+	// no arguments.
+	GetParameters(control IOletControl) Parameters
+	GetControls() []IOletControl
 	FireAction(ctx context.Context, control IOletControl) error
+	FireActionWithArguments(ctx context.Context, control IOletControl, args Arguments) error
+	// SetOnChange registers a callback invoked after every change of the
+	// iolet. Modules register themselves in AddIOlet.
+	SetOnChange(onChange func())
 	Updated() *IOletUpdate
 }
 
 func NewIOlet(id IOletId, ioletType IOletType, name string) IOlet {
 	return &ioletImpl{
-		Id:       id,
-		Type:     ioletType,
-		Name:     name,
-		Status:   0,
-		Controls: make([]IOletControl, 0),
-		actions:  make(map[IOletControl]IOletAction),
-		modified: atomic.Bool{},
+		Id:         id,
+		Type:       ioletType,
+		Name:       name,
+		Status:     0,
+		Controls:   make([]IOletControl, 0),
+		actions:    make(map[IOletControl]IOletParameterizedAction),
+		parameters: make(map[IOletControl]Parameters),
+		modified:   atomic.Bool{},
 	}
 }
 
+// IOletsFromJSON decodes a list of iolets.
+func IOletsFromJSON(decoder *json.Decoder) ([]IOlet, error) {
+	var snapshots []ioletSnapshot
+	if err := decoder.Decode(&snapshots); err != nil {
+		return nil, err
+	}
+	iolets := make([]IOlet, 0)
+	for _, snapshot := range snapshots {
+		iolets = append(iolets, snapshot.iolet())
+	}
+	return iolets, nil
+}
+
+// IOletFromJSON decodes a single iolet.
+func IOletFromJSON(decoder *json.Decoder) (IOlet, error) {
+	var snapshot ioletSnapshot
+	if err := decoder.Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot.iolet(), nil
+}
+
 type ioletImpl struct {
-	Id       IOletId        `json:"id"`
-	Type     IOletType      `json:"type"`
-	Name     string         `json:"name"`
-	Status   IOletStatus    `json:"status"`
-	Controls []IOletControl `json:"controls"`
-	actions  map[IOletControl]IOletAction
+	mutex      sync.RWMutex
+	Id         IOletId
+	Type       IOletType
+	Name       string
+	Status     IOletStatus
+	Controls   []IOletControl
+	actions    map[IOletControl]IOletParameterizedAction
+	parameters map[IOletControl]Parameters
+	stream     *StreamDescriptor
+	// events are the GPIO edges since the last update, changed is the time
+	// of the last one
+	events   []GPIOEvent
+	changed  time.Time
+	ptp      *PTPStatus
 	modified atomic.Bool
+	onChange atomic.Pointer[func()]
+	// streamModified and ptpModified are set when the stream or the PTP
+	// state changed since the last update
+	streamModified bool
+	ptpModified    bool
 }
 
 type IOletUpdate struct {
@@ -45,39 +99,89 @@ type IOletUpdate struct {
 	Type   IOletType   `json:"type"`
 	Name   string      `json:"name"`
 	Status IOletStatus `json:"status"`
+	// Stream is only set when the stream descriptor changed
+	Stream *StreamDescriptor `json:"stream,omitempty"`
+	// Events lists the GPIO edges since the last update, oldest first
+	Events []GPIOEvent `json:"events,omitempty"`
+	// PTP is only set when the PTP state changed
+	PTP *PTPStatus `json:"ptp,omitempty"`
 }
 
 func (iolet *ioletImpl) GetId() IOletId {
+	iolet.mutex.RLock()
+	defer iolet.mutex.RUnlock()
 	return iolet.Id
 }
 
 func (iolet *ioletImpl) GetName() string {
+	iolet.mutex.RLock()
+	defer iolet.mutex.RUnlock()
 	return iolet.Name
 }
 
 func (iolet *ioletImpl) GetType() IOletType {
+	iolet.mutex.RLock()
+	defer iolet.mutex.RUnlock()
 	return iolet.Type
 }
 
 func (iolet *ioletImpl) SetName(newName string) {
+	iolet.mutex.Lock()
+	defer iolet.mutex.Unlock()
 	if iolet.Name != newName && newName != "" {
 		iolet.Name = newName
-		iolet.modified.Store(true)
+		iolet.markModified()
 	}
 }
 
 func (iolet *ioletImpl) GetStatus() IOletStatus {
+	iolet.mutex.RLock()
+	defer iolet.mutex.RUnlock()
 	return iolet.Status
 }
+
 func (iolet *ioletImpl) SetStatus(newStatus IOletStatus) {
-	if iolet.Status != newStatus {
-		iolet.Status = newStatus
-		iolet.modified.Store(true)
+	iolet.mutex.Lock()
+	defer iolet.mutex.Unlock()
+	iolet.applyStatus(newStatus, time.Now())
+}
+
+func (iolet *ioletImpl) ModifyStatus(modify func(status *IOletStatus)) {
+	iolet.mutex.Lock()
+	defer iolet.mutex.Unlock()
+	newStatus := iolet.Status
+	modify(&newStatus)
+	iolet.applyStatus(newStatus, time.Now())
+}
+
+// applyStatus records an edge event when the level of a GPIO iolet changes.
+// It expects the caller to hold the write lock.
+func (iolet *ioletImpl) applyStatus(newStatus IOletStatus, at time.Time) {
+	if iolet.Status == newStatus {
+		return
 	}
+	if iolet.Type.GPIO() && iolet.Status.High() != newStatus.High() {
+		iolet.recordEdge(newStatus.High(), at)
+	}
+	iolet.Status = newStatus
+	iolet.markModified()
 }
 
 func (iolet *ioletImpl) AddAction(newControl IOletControl, action IOletAction) {
+	iolet.AddParameterizedAction(newControl, nil, func(ctx context.Context, iolet IOlet, args Arguments) error {
+		return action(ctx, iolet)
+	})
+}
+
+func (iolet *ioletImpl) AddParameterizedAction(newControl IOletControl, parameters Parameters, action IOletParameterizedAction) {
+	iolet.mutex.Lock()
+	defer iolet.mutex.Unlock()
 	iolet.actions[newControl] = action
+	if len(parameters) > 0 {
+		iolet.parameters[newControl] = parameters
+	} else {
+		delete(iolet.parameters, newControl)
+	}
 
 	for _, control := range iolet.Controls {
 		if control == newControl {
@@ -87,25 +191,172 @@ func (iolet *ioletImpl) AddAction(newControl IOletControl, action IOletAction) {
 	iolet.Controls = append(iolet.Controls, newControl)
 }
 
+func (iolet *ioletImpl) RemoveAction(control IOletControl) {
+	iolet.mutex.Lock()
+	defer iolet.mutex.Unlock()
+	delete(iolet.actions, control)
+	delete(iolet.parameters, control)
+	iolet.Controls = slices.DeleteFunc(iolet.Controls, func(listed IOletControl) bool { return listed == control })
+}
+
+func (iolet *ioletImpl) GetControls() []IOletControl {
+	iolet.mutex.RLock()
+	defer iolet.mutex.RUnlock()
+	return append([]IOletControl(nil), iolet.Controls...)
+}
+
+func (iolet *ioletImpl) GetParameters(control IOletControl) Parameters {
+	iolet.mutex.RLock()
+	defer iolet.mutex.RUnlock()
+	return iolet.parameters[control]
+}
+
 func (iolet *ioletImpl) FireAction(ctx context.Context, control IOletControl) error {
-	if action, ok := iolet.actions[control]; ok {
-		return action(ctx, iolet)
+	return iolet.FireActionWithArguments(ctx, control, nil)
+}
+
+func (iolet *ioletImpl) FireActionWithArguments(ctx context.Context, control IOletControl, args Arguments) error {
+	iolet.mutex.RLock()
+	action, ok := iolet.actions[control]
+	parameters := iolet.parameters[control]
+	iolet.mutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrControlNotSupported, control)
+	}
+	bound, err := parameters.Bind(args)
+	if err != nil {
+		return err
+	}
+	return action(ctx, iolet, bound)
+}
+
+func (iolet *ioletImpl) SetOnChange(onChange func()) {
+	if onChange == nil {
+		iolet.onChange.Store(nil)
+		return
+	}
+	iolet.onChange.Store(&onChange)
+}
+
+// markModified expects the caller to hold the write lock.
+func (iolet *ioletImpl) markModified() {
+	iolet.modified.Store(true)
+	if onChange := iolet.onChange.Load(); onChange != nil {
+		(*onChange)()
 	}
-	return fmt.Errorf("no such action defined")
 }
 
 func (iolet *ioletImpl) Updated() *IOletUpdate {
+	iolet.mutex.Lock()
+	defer iolet.mutex.Unlock()
 	if iolet.modified.Swap(false) {
-		return &IOletUpdate{
+		update := &IOletUpdate{
 			Id:     iolet.Id,
 			Type:   iolet.Type,
 			Name:   iolet.Name,
 			Status: iolet.Status,
 		}
+		if iolet.streamModified {
+			update.Stream = iolet.stream.Clone()
+			iolet.streamModified = false
+		}
+		if iolet.ptpModified {
+			update.PTP = iolet.ptp.clone()
+			iolet.ptpModified = false
+		}
+		update.Events = iolet.events
+		iolet.events = nil
+		return update
 	}
 	return nil
 }
 
+// completeIOletUpdate reports iolet regardless of whether it was modified.
+func completeIOletUpdate(iolet IOlet) IOletUpdate {
+	var events []GPIOEvent
+	if update := iolet.Updated(); update != nil {
+		events = update.Events
+	}
+	return IOletUpdate{
+		Id:     iolet.GetId(),
+		Type:   iolet.GetType(),
+		Name:   iolet.GetName(),
+		Status: iolet.GetStatus(),
+		Stream: StreamOf(iolet),
+		Events: events,
+		PTP:    PTPStatusOf(iolet),
+	}
+}
+
+// ioletSnapshot is the JSON representation of an iolet, see deviceSnapshot.
+type ioletSnapshot struct {
+	Id                IOletId                     `json:"id"`
+	Type              IOletType                   `json:"type"`
+	Name              string                      `json:"name"`
+	Status            IOletStatus                 `json:"status"`
+	Controls          []IOletControl              `json:"controls"`
+	ControlParameters map[IOletControl]Parameters `json:"controlParameters,omitempty"`
+	Stream            *StreamDescriptor           `json:"stream,omitempty"`
+	PTP               *PTPStatus                  `json:"ptp,omitempty"`
+}
+
+func (iolet *ioletImpl) snapshot() ioletSnapshot {
+	iolet.mutex.RLock()
+	defer iolet.mutex.RUnlock()
+	return ioletSnapshot{
+		Id:                iolet.Id,
+		Type:              iolet.Type,
+		Name:              iolet.Name,
+		Status:            iolet.Status,
+		Controls:          append([]IOletControl{}, iolet.Controls...),
+		ControlParameters: maps.Clone(iolet.parameters),
+		Stream:            iolet.stream.Clone(),
+		PTP:               iolet.ptp.clone(),
+	}
+}
+
+func ioletControlParameters(iolet IOlet) map[IOletControl]Parameters {
+	parameters := make(map[IOletControl]Parameters)
+	for _, control := range iolet.GetControls() {
+		if controlParameters := iolet.GetParameters(control); len(controlParameters) > 0 {
+			parameters[control] = controlParameters
+		}
+	}
+	return parameters
+}
+
+func (iolet *ioletImpl) MarshalJSON() ([]byte, error) {
+	return json.Marshal(iolet.snapshot())
+}
+
+func ioletTreeSnapshot(iolet IOlet) ioletSnapshot {
+	if impl, ok := iolet.(*ioletImpl); ok {
+		return impl.snapshot()
+	}
+	return ioletSnapshot{
+		Id:                iolet.GetId(),
+		Type:              iolet.GetType(),
+		Name:              iolet.GetName(),
+		Status:            iolet.GetStatus(),
+		Controls:          append([]IOletControl{}, iolet.GetControls()...),
+		ControlParameters: ioletControlParameters(iolet),
+		Stream:            StreamOf(iolet),
+		PTP:               PTPStatusOf(iolet),
+	}
+}
+
+func (snapshot ioletSnapshot) iolet() IOlet {
+	iolet := NewIOlet(snapshot.Id, snapshot.Type, snapshot.Name).(*ioletImpl)
+	iolet.Status = snapshot.Status
+	iolet.Controls = append(iolet.Controls, snapshot.Controls...)
+	for control, parameters := range snapshot.ControlParameters {
+		iolet.parameters[control] = parameters
+	}
+	iolet.stream = snapshot.Stream
+	iolet.ptp = snapshot.PTP
+	return iolet
+}
+
 type IOletId string
 
 type IOletType string
@@ -118,12 +369,14 @@ const (
 	IOletType_IPDATA     IOletType = "IP-DATA"
 	IOletType_IPTIMING   IOletType = "IP-TIMING"
 	IOletType_IPGPIO     IOletType = "IP-GPI"
+	IOletType_IPGPO      IOletType = "IP-GPO"
 	IOletType_BBVIDEOIN  IOletType = "BB-VIDEO-IN"
 	IOletType_BBVIDEOOUT IOletType = "BB-VIDEO-OUT"
 	IOletType_BBAUDIOIN  IOletType = "BB-AUDIO-IN"
 	IOletType_BBAUDIOOUT IOletType = "BB-AUDIO-OUT"
 	IOletType_BBTIMING   IOletType = "BB-TIMING"
 	IOletType_BBGPIO     IOletType = "BB-GPI"
+	IOletType_BBGPO      IOletType = "BB-GPO"
 )
 
 type IOletStatus uint8
@@ -201,12 +454,30 @@ const (
 	IOletControl_RESTART IOletControl = "RESTART"
 )
 
+// Valid reports whether the control is registered, see RegisterIOletControl.
+// Elements may still support controls which are not registered.
 func (control IOletControl) Valid() error {
-	valid := control == IOletControl_START || control == IOletControl_STOP || control == IOletControl_RESTART
-	if valid {
+	if _, ok := ioletControls.lookup(string(control)); ok {
 		return nil
 	}
 	return fmt.Errorf("%s is not a valid control", control)
 }
 
+// Definition returns the registered label and description of the control.
+func (control IOletControl) Definition() ControlDefinition {
+	if definition, ok := ioletControls.lookup(string(control)); ok {
+		return definition
+	}
+	return ControlDefinition{Control: string(control), Label: string(control)}
+}
+
 type IOletAction func(ctx context.Context, iolet IOlet) error
+
+type IOletParameterizedAction func(ctx context.Context, iolet IOlet, args Arguments) error
+
+// IOletActionRemover is implemented by iolets whose actions can be removed
+// again, like those of NewIOlet. Firing a removed control fails with
+// ErrControlNotSupported.
+type IOletActionRemover interface {
+	RemoveAction(control IOletControl)
+}
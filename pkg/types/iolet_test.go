@@ -1,6 +1,10 @@
 package types
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
 
 func TestIOletStatus(t *testing.T) {
 	ioLetStatus := IOletStatus(0)
@@ -136,3 +140,20 @@ func TestIOletStatuses(t *testing.T) {
 		t.Error("should be sending")
 	}
 }
+
+func TestIOletJSON(t *testing.T) {
+	iolet := NewIOlet("1", IOletType_IPAUDIOIN, "Audio In")
+	iolet.SetStatus(IOletStatus_RECEIVING)
+
+	body, err := json.Marshal([]IOlet{iolet})
+	if err != nil {
+		t.Fatal(err)
+	}
+	iolets, err := IOletsFromJSON(json.NewDecoder(bytes.NewReader(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(iolets) != 1 || iolets[0].GetName() != "Audio In" || !iolets[0].GetStatus().Receiving() {
+		t.Error("iolet not restored")
+	}
+}
@@ -0,0 +1,105 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	// JOB_CONCURRENCY is the number of jobs running at once, further jobs
+	// are queued
+	JOB_CONCURRENCY = 4
+	// JOB_RETENTION is how long finished jobs are listed
+	JOB_RETENTION = time.Hour
+)
+
+type JobId string
+
+type JobState string
+
+const (
+	JobState_QUEUED    JobState = "queued"
+	JobState_RUNNING   JobState = "running"
+	JobState_SUCCEEDED JobState = "succeeded"
+	JobState_FAILED    JobState = "failed"
+	JobState_CANCELLED JobState = "cancelled"
+)
+
+// Done reports whether the state is final.
+func (state JobState) Done() bool {
+	return state == JobState_SUCCEEDED || state == JobState_FAILED || state == JobState_CANCELLED
+}
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobDone is returned when cancelling a job which already finished.
+	ErrJobDone = errors.New("job already done")
+)
+
+// JobRequest asks to run a control as a job instead of within the request.
+type JobRequest struct {
+	Path      ElementPath `json:"path"`
+	Control   string      `json:"control"`
+	Arguments Arguments   `json:"arguments,omitempty"`
+	// TimeoutMs limits the running job in milliseconds, zero means no limit
+	TimeoutMs int64 `json:"timeoutMs,omitempty"`
+}
+
+// Timeout returns the time the running job may take, zero if it is not
+// limited.
+func (request JobRequest) Timeout() time.Duration {
+	return time.Duration(request.TimeoutMs) * time.Millisecond
+}
+
+func (request JobRequest) Valid() error {
+	if request.Path.DeviceId == "" {
+		return errors.New("path is missing")
+	}
+	if request.Control == "" {
+		return errors.New("control is missing")
+	}
+	if request.TimeoutMs < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	return nil
+}
+
+// Job is a control running in the background. Its state goes from queued to
+// running and ends as succeeded, failed or cancelled.
+type Job struct {
+	Id      JobId       `json:"id"`
+	Path    ElementPath `json:"path"`
+	Control string      `json:"control"`
+	State   JobState    `json:"state"`
+	// Progress from 0 to 1 and Message are reported by the action, see
+	// ReportProgress
+	Progress float64    `json:"progress"`
+	Message  string     `json:"message,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// ProgressReporter receives the progress of an action running as a job.
+type ProgressReporter func(progress float64, message string)
+
+type progressKey struct{}
+
+// WithProgressReporter returns a context which passes the progress reported
+// by an action to reporter.
+func WithProgressReporter(ctx context.Context, reporter ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressKey{}, reporter)
+}
+
+// ReportProgress lets a long running action report its progress from 0 to 1,
+// e.g. while a firmware is uploaded. It does nothing if the action does not
+// run as a job.
+func ReportProgress(ctx context.Context, progress float64, message string) {
+	reporter, ok := ctx.Value(progressKey{}).(ProgressReporter)
+	if !ok {
+		return
+	}
+	reporter(min(max(progress, 0), 1), message)
+}
@@ -0,0 +1,28 @@
+package types
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReportProgress(t *testing.T) {
+	// actions not running as job may report progress
+	ReportProgress(context.Background(), 0.5, "ignored")
+
+	var progress float64
+	var message string
+	ctx := WithProgressReporter(context.Background(), func(p float64, m string) {
+		progress, message = p, m
+	})
+	ReportProgress(ctx, 1.5, "uploading")
+	if progress != 1 || message != "uploading" {
+		t.Errorf("progress should be clamped, got %g %s", progress, message)
+	}
+
+	if err := (JobRequest{Control: "REBOOT"}).Valid(); err == nil {
+		t.Error("job without path should not be valid")
+	}
+	if !JobState_CANCELLED.Done() || JobState_RUNNING.Done() {
+		t.Error("only final states should be done")
+	}
+}
@@ -0,0 +1,100 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func lookupTestDevice(modules int, iolets int) Device {
+	device := NewDevice("1", DeviceType__GENERIC_DUMMY, "Router")
+	for i := 0; i < modules; i++ {
+		module := NewModule(ModuleId(fmt.Sprint(i)), ModuleType_AV, fmt.Sprintf("Card %d", i))
+		for j := 0; j < iolets; j++ {
+			module.AddIOlet(NewIOlet(IOletId(fmt.Sprint(j)), IOletType_IPAUDIOIN, fmt.Sprintf("Input %d", j)))
+		}
+		device.AddModule(module)
+	}
+	return device
+}
+
+func TestIndexedLookups(t *testing.T) {
+	device := lookupTestDevice(3, 3)
+	module := device.GetModule("1")
+	if module == nil || module.GetName() != "Card 1" || device.GetModule("3") != nil {
+		t.Fatalf("unexpected module %v", module)
+	}
+
+	first := module.GetIOlet("2")
+	duplicate := NewIOlet("2", IOletType_IPAUDIOOUT, "Duplicate")
+	if err := module.(IOletAdder).AddIOletChecked(duplicate); !errors.Is(err, ErrDuplicateId) {
+		t.Errorf("checked duplicate iolet should be refused, got %v", err)
+	}
+	if module.GetIOlet("2") != first || len(module.GetIOlets()) != 3 {
+		t.Error("refused iolet should leave the module unchanged")
+	}
+	if err := device.(ModuleAdder).AddModuleChecked(NewModule("1", ModuleType_GPIO, "GPIO")); !errors.Is(err, ErrDuplicateId) {
+		t.Errorf("checked duplicate module should be refused, got %v", err)
+	}
+	module.AddIOlet(duplicate)
+	if module.GetIOlet("2") != duplicate || len(module.GetIOlets()) != 3 {
+		t.Error("last added iolet should win for duplicate ids")
+	}
+	module.RemoveIOlet("2")
+	if err := module.(IOletAdder).AddIOletChecked(first); err != nil || module.GetIOlet("2") != first {
+		t.Errorf("removed id should be free again, got %v", err)
+	}
+	replacement := NewIOlet("2", IOletType_IPAUDIOIN, "Replacement")
+	module.ReplaceIOlet(replacement)
+	if module.GetIOlet("2") != replacement {
+		t.Error("replaced iolet should be indexed")
+	}
+	module.RemoveIOlet("2")
+	if module.GetIOlet("2") != nil {
+		t.Error("removed iolet should not be found")
+	}
+
+	replaced := NewModule("1", ModuleType_GPIO, "GPIO")
+	device.ReplaceModule(replaced)
+	if device.GetModule("1") != replaced {
+		t.Error("replaced module should be indexed")
+	}
+	device.RemoveModule("1")
+	if device.GetModule("1") != nil {
+		t.Error("removed module should not be found")
+	}
+}
+
+// BenchmarkGetIOlet looks up the last iolet of the last module, the worst
+// case of a linear scan, see BenchmarkGetIOletScan.
+func BenchmarkGetIOlet(b *testing.B) {
+	device := lookupTestDevice(64, 256)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if device.GetModule("63").GetIOlet("255") == nil {
+			b.Fatal("iolet not found")
+		}
+	}
+}
+
+// BenchmarkGetIOletScan is the linear scan GetModule and GetIOlet did
+// before they were indexed.
+func BenchmarkGetIOletScan(b *testing.B) {
+	device := lookupTestDevice(64, 256)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var found IOlet
+		for _, module := range device.GetModules() {
+			if module.GetId() == "63" {
+				for _, iolet := range module.GetIOlets() {
+					if iolet.GetId() == "255" {
+						found = iolet
+					}
+				}
+			}
+		}
+		if found == nil {
+			b.Fatal("iolet not found")
+		}
+	}
+}
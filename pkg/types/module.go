@@ -2,87 +2,210 @@ package types
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"sync"
 	"sync/atomic"
 )
 
 type Module interface {
 	GetId() ModuleId
 	GetType() ModuleType
+	GetName() string
 	SetName(newName string)
 	GetStatus() ModuleStatus
 	SetStatus(newStatus ModuleStatus)
+	// ModifyStatus atomically applies modify to the current status.
+	ModifyStatus(modify func(status *ModuleStatus))
 	AddAction(newControl ModuleControl, action ModuleAction)
+	// AddParameterizedAction registers an action whose arguments are
+	// validated against parameters before it is called.
+	AddParameterizedAction(control ModuleControl, parameters Parameters, action ModuleParameterizedAction)
+	// GetParameters returns the argument schema of control, nil if it takes
+	// no arguments.
+	GetParameters(control ModuleControl) Parameters
+	GetControls() []ModuleControl
 	FireAction(ctx context.Context, control ModuleControl) error
+	FireActionWithArguments(ctx context.Context, control ModuleControl, args Arguments) error
+	// AddIOlet adds newIOlet. An iolet with the same id is replaced like by
+	// ReplaceIOlet, see IOletAdder to refuse it instead.
 	AddIOlet(newIOlet IOlet)
+	// RemoveIOlet removes the iolet with the given id and returns it, or nil
+	// if there is no such iolet. The next update reports the removal.
+	RemoveIOlet(ioletId IOletId) IOlet
+	// ReplaceIOlet replaces the iolet with the same id, or adds it if there is
+	// none, and returns the old iolet. The next update reports the new iolet.
+	ReplaceIOlet(newIOlet IOlet) IOlet
 	GetIOletTypes() []IOletType
 	GetIOlets() []IOlet
 	GetIOletsByType(ioletType IOletType) []IOlet
 	GetIOlet(ioletId IOletId) IOlet
+	// SetOnChange registers a callback invoked after every change of the
+	// module or its iolets. Devices register themselves in AddModule.
+	SetOnChange(onChange func())
 	Updated() *ModuleUpdate
 }
 
+// IOletAdder is implemented by modules which can refuse an iolet whose id is
+// taken, like those of NewModule.
+type IOletAdder interface {
+	// AddIOletChecked adds newIOlet, it fails with ErrDuplicateId and leaves
+	// the module unchanged if an iolet has its id.
+	AddIOletChecked(newIOlet IOlet) error
+}
+
 func NewModule(id ModuleId, moduleType ModuleType, name string) Module {
 	return &moduleImpl{
 		Id:         id,
 		Type:       moduleType,
 		Name:       name,
 		Controls:   make([]ModuleControl, 0),
-		actions:    make(map[ModuleControl]ModuleAction),
+		actions:    make(map[ModuleControl]ModuleParameterizedAction),
+		parameters: make(map[ModuleControl]Parameters),
 		IOletTypes: make([]IOletType, 0),
 		IOlets:     make([]IOlet, 0),
+		ioletsById: make(map[IOletId]IOlet),
 		modified:   atomic.Bool{},
 	}
 }
 
+// ModulesFromJSON decodes a list of modules including their iolets.
+func ModulesFromJSON(decoder *json.Decoder) ([]Module, error) {
+	var snapshots []moduleSnapshot
+	if err := decoder.Decode(&snapshots); err != nil {
+		return nil, err
+	}
+	modules := make([]Module, 0)
+	for _, snapshot := range snapshots {
+		module, err := snapshot.module()
+		if err != nil {
+			return nil, err
+		}
+		modules = append(modules, module)
+	}
+	return modules, nil
+}
+
+// ModuleFromJSON decodes a single module including its iolets.
+func ModuleFromJSON(decoder *json.Decoder) (Module, error) {
+	var snapshot moduleSnapshot
+	if err := decoder.Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot.module()
+}
+
+// ModuleToJSON encodes a single module including its iolets.
+func ModuleToJSON(encoder *json.Encoder, module Module) error {
+	return encoder.Encode(moduleTreeSnapshot(module))
+}
+
 type moduleImpl struct {
-	Id         ModuleId        `json:"id"`
-	Type       ModuleType      `json:"type"`
-	Name       string          `json:"name"`
-	Status     ModuleStatus    `json:"status"`
-	Controls   []ModuleControl `json:"controls"`
-	actions    map[ModuleControl]ModuleAction
-	IOletTypes []IOletType `json:"ioletTypes"`
-	IOlets     []IOlet     `json:"-"`
+	mutex      sync.RWMutex
+	Id         ModuleId
+	Type       ModuleType
+	Name       string
+	Status     ModuleStatus
+	Controls   []ModuleControl
+	actions    map[ModuleControl]ModuleParameterizedAction
+	parameters map[ModuleControl]Parameters
+	IOletTypes []IOletType
+	IOlets     []IOlet
+	// ioletsById indexes IOlets, for duplicate ids the first iolet wins
+	ioletsById map[IOletId]IOlet
+	power      *PowerStatus
 	modified   atomic.Bool
+	// removedIOlets and replacedIOlets are reported by the next update
+	removedIOlets  []IOletId
+	replacedIOlets []IOletId
+	onChange       atomic.Pointer[func()]
 }
 
 type ModuleUpdate struct {
-	Id     ModuleId      `json:"id"`
-	Type   ModuleType    `json:"type"`
-	Name   string        `json:"name"`
-	Status ModuleStatus  `json:"status"`
-	IOlets []IOletUpdate `json:"iolets"`
+	Id            ModuleId      `json:"id"`
+	Type          ModuleType    `json:"type"`
+	Name          string        `json:"name"`
+	Status        ModuleStatus  `json:"status"`
+	IOlets        []IOletUpdate `json:"iolets"`
+	RemovedIOlets []IOletId     `json:"removedIOlets,omitempty"`
+	// Replaced is set when the whole module was replaced. IOlets then lists
+	// every iolet of the module and all others have to be dropped.
+	Replaced bool `json:"replaced,omitempty"`
+	// Power is the current state of a POWER module, reported with every
+	// update so error checkers always see it
+	Power *PowerStatus `json:"power,omitempty"`
 }
 
 func (module *moduleImpl) GetId() ModuleId {
+	module.mutex.RLock()
+	defer module.mutex.RUnlock()
 	return module.Id
 }
 
 func (module *moduleImpl) GetType() ModuleType {
+	module.mutex.RLock()
+	defer module.mutex.RUnlock()
 	return module.Type
 }
 
+func (module *moduleImpl) GetName() string {
+	module.mutex.RLock()
+	defer module.mutex.RUnlock()
+	return module.Name
+}
+
 func (module *moduleImpl) SetName(newName string) {
-	if module.Name == newName && newName != "" {
+	module.mutex.Lock()
+	defer module.mutex.Unlock()
+	if module.Name != newName && newName != "" {
 		module.Name = newName
-		module.modified.Store(true)
+		module.markModified()
 	}
 }
 
 func (module *moduleImpl) GetStatus() ModuleStatus {
+	module.mutex.RLock()
+	defer module.mutex.RUnlock()
 	return module.Status
 }
 
 func (module *moduleImpl) SetStatus(newStatus ModuleStatus) {
+	module.mutex.Lock()
+	defer module.mutex.Unlock()
 	if module.Status != newStatus {
 		module.Status = newStatus
-		module.modified.Store(true)
+		module.markModified()
+	}
+}
+
+func (module *moduleImpl) ModifyStatus(modify func(status *ModuleStatus)) {
+	module.mutex.Lock()
+	defer module.mutex.Unlock()
+	newStatus := module.Status
+	modify(&newStatus)
+	if module.Status != newStatus {
+		module.Status = newStatus
+		module.markModified()
 	}
 }
 
 func (module *moduleImpl) AddAction(newControl ModuleControl, action ModuleAction) {
+	module.AddParameterizedAction(newControl, nil, func(ctx context.Context, module Module, args Arguments) error {
+		return action(ctx, module)
+	})
+}
+
+func (module *moduleImpl) AddParameterizedAction(newControl ModuleControl, parameters Parameters, action ModuleParameterizedAction) {
+	module.mutex.Lock()
+	defer module.mutex.Unlock()
 	module.actions[newControl] = action
+	if len(parameters) > 0 {
+		module.parameters[newControl] = parameters
+	} else {
+		delete(module.parameters, newControl)
+	}
 
 	for _, control := range module.Controls {
 		if control == newControl {
@@ -92,17 +215,44 @@ func (module *moduleImpl) AddAction(newControl ModuleControl, action ModuleActio
 	module.Controls = append(module.Controls, newControl)
 }
 
+func (module *moduleImpl) GetControls() []ModuleControl {
+	module.mutex.RLock()
+	defer module.mutex.RUnlock()
+	return append([]ModuleControl(nil), module.Controls...)
+}
+
+func (module *moduleImpl) GetParameters(control ModuleControl) Parameters {
+	module.mutex.RLock()
+	defer module.mutex.RUnlock()
+	return module.parameters[control]
+}
+
 func (module *moduleImpl) FireAction(ctx context.Context, control ModuleControl) error {
-	if action, ok := module.actions[control]; ok {
-		return action(ctx, module)
+	return module.FireActionWithArguments(ctx, control, nil)
+}
+
+func (module *moduleImpl) FireActionWithArguments(ctx context.Context, control ModuleControl, args Arguments) error {
+	module.mutex.RLock()
+	action, ok := module.actions[control]
+	parameters := module.parameters[control]
+	module.mutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrControlNotSupported, control)
 	}
-	return fmt.Errorf("no such action defined")
+	bound, err := parameters.Bind(args)
+	if err != nil {
+		return err
+	}
+	return action(ctx, module, bound)
 }
 
 func (module *moduleImpl) GetIOletTypes() []IOletType {
-	return module.IOletTypes
+	module.mutex.RLock()
+	defer module.mutex.RUnlock()
+	return append([]IOletType(nil), module.IOletTypes...)
 }
 
+// addIOletType expects the caller to hold the write lock.
 func (module *moduleImpl) addIOletType(newIOletType IOletType) {
 	for _, ioletType := range module.IOletTypes {
 		if newIOletType == ioletType {
@@ -113,17 +263,112 @@ func (module *moduleImpl) addIOletType(newIOletType IOletType) {
 }
 
 func (module *moduleImpl) AddIOlet(newIOlet IOlet) {
-	module.addIOletType(newIOlet.GetType())
+	module.ReplaceIOlet(newIOlet)
+}
+
+func (module *moduleImpl) AddIOletChecked(newIOlet IOlet) error {
+	ioletId := newIOlet.GetId()
+	ioletType := newIOlet.GetType()
+	module.mutex.Lock()
+	defer module.mutex.Unlock()
+	if _, ok := module.ioletsById[ioletId]; ok {
+		return fmt.Errorf("%w: iolet %s of module %s", ErrDuplicateId, ioletId, module.Id)
+	}
+	module.addIOlet(ioletId, ioletType, newIOlet)
+	return nil
+}
+
+// addIOlet expects the caller to hold the write lock.
+func (module *moduleImpl) addIOlet(ioletId IOletId, ioletType IOletType, newIOlet IOlet) {
+	module.addIOletType(ioletType)
 	module.IOlets = append(module.IOlets, newIOlet)
+	if _, ok := module.ioletsById[ioletId]; !ok {
+		module.ioletsById[ioletId] = newIOlet
+	}
+	newIOlet.SetOnChange(module.notify)
+	if slices.Contains(module.removedIOlets, ioletId) {
+		module.removedIOlets = slices.DeleteFunc(module.removedIOlets, func(id IOletId) bool { return id == ioletId })
+		module.replacedIOlets = append(module.replacedIOlets, ioletId)
+		module.notify()
+	}
+}
+
+// removeIOletType expects the caller to hold the write lock.
+func (module *moduleImpl) removeIOletType(oldIOletType IOletType) {
+	for _, iolet := range module.IOlets {
+		if iolet.GetType() == oldIOletType {
+			return
+		}
+	}
+	module.IOletTypes = slices.DeleteFunc(module.IOletTypes, func(ioletType IOletType) bool { return ioletType == oldIOletType })
+}
+
+// reindexIOlet expects the caller to hold the write lock.
+func (module *moduleImpl) reindexIOlet(ioletId IOletId) {
+	delete(module.ioletsById, ioletId)
+	if index := module.indexOfIOlet(ioletId); index >= 0 {
+		module.ioletsById[ioletId] = module.IOlets[index]
+	}
+}
+
+// indexOfIOlet expects the caller to hold the lock.
+func (module *moduleImpl) indexOfIOlet(ioletId IOletId) int {
+	return slices.IndexFunc(module.IOlets, func(iolet IOlet) bool { return iolet.GetId() == ioletId })
+}
+
+func (module *moduleImpl) RemoveIOlet(ioletId IOletId) IOlet {
+	module.mutex.Lock()
+	defer module.mutex.Unlock()
+	index := module.indexOfIOlet(ioletId)
+	if index < 0 {
+		return nil
+	}
+	removed := module.IOlets[index]
+	removed.SetOnChange(nil)
+	module.IOlets = slices.Delete(module.IOlets, index, index+1)
+	module.reindexIOlet(ioletId)
+	module.removeIOletType(removed.GetType())
+	module.replacedIOlets = slices.DeleteFunc(module.replacedIOlets, func(id IOletId) bool { return id == ioletId })
+	if !slices.Contains(module.removedIOlets, ioletId) {
+		module.removedIOlets = append(module.removedIOlets, ioletId)
+	}
+	module.notify()
+	return removed
+}
+
+func (module *moduleImpl) ReplaceIOlet(newIOlet IOlet) IOlet {
+	ioletId := newIOlet.GetId()
+	ioletType := newIOlet.GetType()
+	module.mutex.Lock()
+	defer module.mutex.Unlock()
+	index := module.indexOfIOlet(ioletId)
+	if index < 0 {
+		module.addIOlet(ioletId, ioletType, newIOlet)
+		return nil
+	}
+	replaced := module.IOlets[index]
+	replaced.SetOnChange(nil)
+	module.IOlets[index] = newIOlet
+	module.reindexIOlet(ioletId)
+	newIOlet.SetOnChange(module.notify)
+	module.addIOletType(ioletType)
+	module.removeIOletType(replaced.GetType())
+	if !slices.Contains(module.replacedIOlets, ioletId) {
+		module.replacedIOlets = append(module.replacedIOlets, ioletId)
+	}
+	module.notify()
+	return replaced
 }
 
 func (module *moduleImpl) GetIOlets() []IOlet {
-	return module.IOlets
+	module.mutex.RLock()
+	defer module.mutex.RUnlock()
+	return append([]IOlet(nil), module.IOlets...)
 }
 
 func (module *moduleImpl) GetIOletsByType(ioletType IOletType) []IOlet {
 	ioletsByType := make([]IOlet, 0)
-	for _, iolet := range module.IOlets {
+	for _, iolet := range module.GetIOlets() {
 		if iolet.GetType() == ioletType {
 			ioletsByType = append(ioletsByType, iolet)
 		}
@@ -133,39 +378,172 @@ func (module *moduleImpl) GetIOletsByType(ioletType IOletType) []IOlet {
 }
 
 func (module *moduleImpl) GetIOlet(ioletId IOletId) IOlet {
-	for _, iolet := range module.IOlets {
-		if iolet.GetId() == ioletId {
-			return iolet
-		}
+	module.mutex.RLock()
+	defer module.mutex.RUnlock()
+	return module.ioletsById[ioletId]
+}
+
+func (module *moduleImpl) SetOnChange(onChange func()) {
+	if onChange == nil {
+		module.onChange.Store(nil)
+		return
+	}
+	module.onChange.Store(&onChange)
+}
+
+// markModified expects the caller to hold the write lock.
+func (module *moduleImpl) markModified() {
+	module.modified.Store(true)
+	module.notify()
+}
+
+func (module *moduleImpl) notify() {
+	if onChange := module.onChange.Load(); onChange != nil {
+		(*onChange)()
 	}
-	return nil
 }
 
 func (module *moduleImpl) Updated() *ModuleUpdate {
+	module.mutex.Lock()
+	iolets := append([]IOlet(nil), module.IOlets...)
+	removedIOlets := module.removedIOlets
+	replacedIOlets := module.replacedIOlets
+	module.removedIOlets = nil
+	module.replacedIOlets = nil
+	module.mutex.Unlock()
+
 	updatedIOlets := make([]IOletUpdate, 0)
-	for _, iolet := range module.IOlets {
+	for _, iolet := range iolets {
+		if slices.Contains(replacedIOlets, iolet.GetId()) {
+			updatedIOlets = append(updatedIOlets, completeIOletUpdate(iolet))
+			continue
+		}
 		updated := iolet.Updated()
 		if updated != nil {
 			updatedIOlets = append(updatedIOlets, *updated)
 		}
 	}
 
-	if module.modified.Swap(false) || len(updatedIOlets) > 0 {
+	module.mutex.RLock()
+	defer module.mutex.RUnlock()
+	if module.modified.Swap(false) || len(updatedIOlets) > 0 || len(removedIOlets) > 0 {
 		return &ModuleUpdate{
-			Id:     module.Id,
-			Type:   module.Type,
-			Name:   module.Name,
-			Status: module.Status,
-			IOlets: updatedIOlets,
+			Id:            module.Id,
+			Type:          module.Type,
+			Name:          module.Name,
+			Status:        module.Status,
+			IOlets:        updatedIOlets,
+			RemovedIOlets: removedIOlets,
+			Power:         module.power.clone(),
 		}
 	}
 	return nil
 }
 
+// completeModuleUpdate reports module with all of its iolets, regardless of
+// what was modified. Pending changes are consumed.
+func completeModuleUpdate(module Module) ModuleUpdate {
+	module.Updated()
+	update := ModuleUpdate{
+		Id:       module.GetId(),
+		Type:     module.GetType(),
+		Name:     module.GetName(),
+		Status:   module.GetStatus(),
+		IOlets:   make([]IOletUpdate, 0),
+		Replaced: true,
+		Power:    PowerStatusOf(module),
+	}
+	for _, iolet := range module.GetIOlets() {
+		update.IOlets = append(update.IOlets, completeIOletUpdate(iolet))
+	}
+	return update
+}
+
 func (module *moduleImpl) Modified() bool {
 	return module.modified.Swap(false)
 }
 
+// moduleSnapshot is the JSON representation of a module, see deviceSnapshot.
+type moduleSnapshot struct {
+	Id                ModuleId                     `json:"id"`
+	Type              ModuleType                   `json:"type"`
+	Name              string                       `json:"name"`
+	Status            ModuleStatus                 `json:"status"`
+	Controls          []ModuleControl              `json:"controls"`
+	ControlParameters map[ModuleControl]Parameters `json:"controlParameters,omitempty"`
+	IOletTypes        []IOletType                  `json:"ioletTypes"`
+	IOlets            []ioletSnapshot              `json:"iolets,omitempty"`
+	Power             *PowerStatus                 `json:"power,omitempty"`
+}
+
+func (module *moduleImpl) snapshot() moduleSnapshot {
+	module.mutex.RLock()
+	defer module.mutex.RUnlock()
+	return moduleSnapshot{
+		Id:                module.Id,
+		Type:              module.Type,
+		Name:              module.Name,
+		Status:            module.Status,
+		Controls:          append([]ModuleControl{}, module.Controls...),
+		ControlParameters: maps.Clone(module.parameters),
+		IOletTypes:        append([]IOletType{}, module.IOletTypes...),
+		Power:             module.power.clone(),
+	}
+}
+
+func moduleControlParameters(module Module) map[ModuleControl]Parameters {
+	parameters := make(map[ModuleControl]Parameters)
+	for _, control := range module.GetControls() {
+		if controlParameters := module.GetParameters(control); len(controlParameters) > 0 {
+			parameters[control] = controlParameters
+		}
+	}
+	return parameters
+}
+
+func (module *moduleImpl) MarshalJSON() ([]byte, error) {
+	return json.Marshal(module.snapshot())
+}
+
+func moduleTreeSnapshot(module Module) moduleSnapshot {
+	var snapshot moduleSnapshot
+	if impl, ok := module.(*moduleImpl); ok {
+		snapshot = impl.snapshot()
+	} else {
+		snapshot = moduleSnapshot{
+			Id:                module.GetId(),
+			Type:              module.GetType(),
+			Name:              module.GetName(),
+			Status:            module.GetStatus(),
+			Controls:          append([]ModuleControl{}, module.GetControls()...),
+			ControlParameters: moduleControlParameters(module),
+			IOletTypes:        append([]IOletType{}, module.GetIOletTypes()...),
+			Power:             PowerStatusOf(module),
+		}
+	}
+	for _, iolet := range module.GetIOlets() {
+		snapshot.IOlets = append(snapshot.IOlets, ioletTreeSnapshot(iolet))
+	}
+	return snapshot
+}
+
+func (snapshot moduleSnapshot) module() (Module, error) {
+	module := NewModule(snapshot.Id, snapshot.Type, snapshot.Name).(*moduleImpl)
+	module.Status = snapshot.Status
+	module.Controls = append(module.Controls, snapshot.Controls...)
+	for control, parameters := range snapshot.ControlParameters {
+		module.parameters[control] = parameters
+	}
+	module.IOletTypes = append(module.IOletTypes, snapshot.IOletTypes...)
+	module.power = snapshot.Power
+	for _, iolet := range snapshot.IOlets {
+		if err := module.AddIOletChecked(iolet.iolet()); err != nil {
+			return nil, err
+		}
+	}
+	return module, nil
+}
+
 type ModuleId string
 
 type ModuleType string
@@ -203,12 +581,23 @@ const (
 	ModuleControl_RESTART ModuleControl = "RESTART"
 )
 
+// Valid reports whether the control is registered, see RegisterModuleControl.
+// Elements may still support controls which are not registered.
 func (control ModuleControl) Valid() error {
-	valid := control == ModuleControl_START || control == ModuleControl_STOP || control == ModuleControl_RESTART
-	if valid {
+	if _, ok := moduleControls.lookup(string(control)); ok {
 		return nil
 	}
 	return fmt.Errorf("%s is not a valid control", control)
 }
 
+// Definition returns the registered label and description of the control.
+func (control ModuleControl) Definition() ControlDefinition {
+	if definition, ok := moduleControls.lookup(string(control)); ok {
+		return definition
+	}
+	return ControlDefinition{Control: string(control), Label: string(control)}
+}
+
 type ModuleAction func(ctx context.Context, module Module) error
+
+type ModuleParameterizedAction func(ctx context.Context, module Module, args Arguments) error
@@ -1,6 +1,10 @@
 package types
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
 
 func TestModuleStatus(t *testing.T) {
 	var moduleStatus = ModuleStatus(0)
@@ -24,3 +28,54 @@ func TestModuleStatus(t *testing.T) {
 		t.Error("module status should be OK")
 	}
 }
+
+func TestModuleJSON(t *testing.T) {
+	module := NewModule("1", ModuleType_GPIO, "GPIO")
+	module.AddIOlet(NewIOlet("1", IOletType_BBGPIO, "GPI 1"))
+	module.SetStatus(ModuleStatus_NOK)
+
+	var buffer bytes.Buffer
+	if err := ModuleToJSON(json.NewEncoder(&buffer), module); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ModuleFromJSON(json.NewDecoder(&buffer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.GetId() != "1" || decoded.GetType() != ModuleType_GPIO || decoded.GetStatus().OK() {
+		t.Error("module fields not restored")
+	}
+	if iolet := decoded.GetIOlet("1"); iolet == nil || iolet.GetName() != "GPI 1" {
+		t.Error("module iolets not restored")
+	}
+}
+
+func TestModuleRemoveIOlet(t *testing.T) {
+	module := NewModule("1", ModuleType_AV, "Video")
+	module.AddIOlet(NewIOlet("1", IOletType_IPVIDEOIN, "In"))
+	module.AddIOlet(NewIOlet("2", IOletType_IPVIDEOOUT, "Out"))
+
+	if removed := module.RemoveIOlet("2"); removed == nil || removed.GetId() != "2" {
+		t.Fatal("iolet 2 should be removed")
+	}
+	if ioletTypes := module.GetIOletTypes(); len(ioletTypes) != 1 || ioletTypes[0] != IOletType_IPVIDEOIN {
+		t.Errorf("iolet type of removed iolet should be gone: %v", ioletTypes)
+	}
+
+	replacement := NewIOlet("1", IOletType_IPVIDEOIN, "Input")
+	module.ReplaceIOlet(replacement)
+	if module.GetIOlet("1") != replacement {
+		t.Error("iolet should be replaced")
+	}
+
+	update := module.Updated()
+	if update == nil || len(update.RemovedIOlets) != 1 || update.RemovedIOlets[0] != "2" {
+		t.Fatal("removal should be reported")
+	}
+	if len(update.IOlets) != 1 || update.IOlets[0].Name != "Input" {
+		t.Error("replaced iolet should be reported")
+	}
+	if module.Updated() != nil {
+		t.Error("changes should be reported once")
+	}
+}
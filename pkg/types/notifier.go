@@ -0,0 +1,58 @@
+package types
+
+import "sync"
+
+// DeviceNotifier is told whenever anything in a device tree changed. Notify is
+// called while locks of the tree may be held, so it must not call back into
+// the device and should return quickly.
+type DeviceNotifier interface {
+	Notify(device Device)
+}
+
+// CoalescingNotifier is a DeviceNotifier which queues every changed device at
+// most once, no matter how many changes happen until the queue is taken.
+type CoalescingNotifier struct {
+	mutex   sync.Mutex
+	pending []Device
+	queued  map[Device]bool
+	signal  chan struct{}
+}
+
+func NewCoalescingNotifier() *CoalescingNotifier {
+	return &CoalescingNotifier{
+		pending: make([]Device, 0),
+		queued:  make(map[Device]bool),
+		signal:  make(chan struct{}, 1),
+	}
+}
+
+func (notifier *CoalescingNotifier) Notify(device Device) {
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+	if notifier.queued[device] {
+		return
+	}
+	notifier.queued[device] = true
+	notifier.pending = append(notifier.pending, device)
+
+	select {
+	case notifier.signal <- struct{}{}:
+	default: // a signal is already waiting
+	}
+}
+
+// Signal receives a value when devices are waiting to be taken.
+func (notifier *CoalescingNotifier) Signal() <-chan struct{} {
+	return notifier.signal
+}
+
+// Take returns all changed devices in order of their first change and
+// empties the queue.
+func (notifier *CoalescingNotifier) Take() []Device {
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+	pending := notifier.pending
+	notifier.pending = make([]Device, 0)
+	notifier.queued = make(map[Device]bool)
+	return pending
+}
@@ -0,0 +1,73 @@
+package types
+
+import "time"
+
+// PowerStatus are the measurements of a POWER module, e.g. of a UPS. Values
+// a device does not report are zero.
+type PowerStatus struct {
+	// BatteryCharge in percent
+	BatteryCharge float64 `json:"batteryCharge"`
+	// BatteryRuntime is the estimated runtime on battery, encoded in
+	// nanoseconds
+	BatteryRuntime time.Duration `json:"batteryRuntime"`
+	// Load in percent of the nominal power
+	Load          float64 `json:"load"`
+	InputVoltage  float64 `json:"inputVoltage"`
+	OutputVoltage float64 `json:"outputVoltage"`
+	OnBattery     bool    `json:"onBattery"`
+	LowBattery    bool    `json:"lowBattery"`
+	// ReplaceBattery is set when the device asks for a new battery
+	ReplaceBattery bool `json:"replaceBattery,omitempty"`
+	Overload       bool `json:"overload,omitempty"`
+}
+
+// OK reports whether the supply is on line power without any alarm.
+func (status PowerStatus) OK() bool {
+	return !status.OnBattery && !status.LowBattery && !status.ReplaceBattery && !status.Overload
+}
+
+func (status *PowerStatus) clone() *PowerStatus {
+	if status == nil {
+		return nil
+	}
+	clone := *status
+	return &clone
+}
+
+// PowerReporter is implemented by modules which carry power measurements,
+// like those of NewModule.
+type PowerReporter interface {
+	// SetPowerStatus sets the measurements of a POWER module.
+	SetPowerStatus(status PowerStatus)
+	// GetPowerStatus returns the power measurements, nil if there are none.
+	GetPowerStatus() *PowerStatus
+}
+
+// PowerStatusOf returns the power measurements of module, nil if it has none
+// or is not a PowerReporter.
+func PowerStatusOf(module Module) *PowerStatus {
+	if reporter, ok := module.(PowerReporter); ok {
+		return reporter.GetPowerStatus()
+	}
+	return nil
+}
+
+// SetPowerStatus sets the measurements and derives the OK flag of the module.
+func (module *moduleImpl) SetPowerStatus(status PowerStatus) {
+	module.mutex.Lock()
+	defer module.mutex.Unlock()
+	newStatus := module.Status
+	newStatus.SetOK(status.OK())
+	if module.power != nil && *module.power == status && module.Status == newStatus {
+		return
+	}
+	module.power = &status
+	module.Status = newStatus
+	module.markModified()
+}
+
+func (module *moduleImpl) GetPowerStatus() *PowerStatus {
+	module.mutex.RLock()
+	defer module.mutex.RUnlock()
+	return module.power.clone()
+}
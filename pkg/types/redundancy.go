@@ -0,0 +1,213 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type RedundancyRole string
+
+const (
+	RedundancyRole_MAIN   RedundancyRole = "main"
+	RedundancyRole_BACKUP RedundancyRole = "backup"
+)
+
+// DeviceControl_FAILOVER makes the standby device of a redundancy group the
+// active one.
+const DeviceControl_FAILOVER DeviceControl = "FAILOVER"
+
+// RedundancyStatus is the state of a redundancy group as seen by one of its
+// devices.
+type RedundancyStatus struct {
+	GroupId string         `json:"groupId"`
+	Role    RedundancyRole `json:"role"`
+	Partner DeviceId       `json:"partner"`
+	// Active is set on the device currently carrying the signal
+	Active bool `json:"active"`
+	// Healthy is set while both devices are online
+	Healthy bool `json:"healthy"`
+}
+
+// FailoverAction switches the pair so that active carries the signal.
+type FailoverAction func(ctx context.Context, group *RedundancyGroup, active Device) error
+
+// RedundancyGroup pairs a main and a backup device. Every update of either
+// device reports the state of the pair, and a status change of one device
+// also updates its partner.
+type RedundancyGroup struct {
+	mutex          sync.RWMutex
+	id             string
+	main           Device
+	backup         Device
+	active         Device
+	failoverAction FailoverAction
+}
+
+// NewRedundancyGroup pairs main and backup with main being active, and adds
+// the FAILOVER control to both devices.
+func NewRedundancyGroup(id string, main Device, backup Device) *RedundancyGroup {
+	group := &RedundancyGroup{
+		id:     id,
+		main:   main,
+		backup: backup,
+		active: main,
+	}
+	failover := func(ctx context.Context, device Device) error {
+		return group.Failover(ctx)
+	}
+	for _, device := range []Device{main, backup} {
+		device.SetRedundancyGroup(group)
+		device.AddAction(DeviceControl_FAILOVER, failover)
+	}
+	return group
+}
+
+func (group *RedundancyGroup) GetId() string {
+	return group.id
+}
+
+func (group *RedundancyGroup) GetMain() Device {
+	return group.main
+}
+
+func (group *RedundancyGroup) GetBackup() Device {
+	return group.backup
+}
+
+// GetPartner returns the other device of the pair, nil if device is not in
+// the group. It does not lock device.
+func (group *RedundancyGroup) GetPartner(device Device) Device {
+	switch device {
+	case group.main:
+		return group.backup
+	case group.backup:
+		return group.main
+	}
+	return nil
+}
+
+func (group *RedundancyGroup) GetActive() Device {
+	group.mutex.RLock()
+	defer group.mutex.RUnlock()
+	return group.active
+}
+
+func (group *RedundancyGroup) SetFailoverAction(action FailoverAction) {
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+	group.failoverAction = action
+}
+
+// SetActive records which device carries the signal, as reported by the
+// devices.
+func (group *RedundancyGroup) SetActive(id DeviceId) error {
+	var active Device
+	switch id {
+	case group.main.GetId():
+		active = group.main
+	case group.backup.GetId():
+		active = group.backup
+	default:
+		return fmt.Errorf("device %s is not in redundancy group %s", id, group.id)
+	}
+	group.mutex.Lock()
+	changed := group.active != active
+	group.active = active
+	group.mutex.Unlock()
+	if changed {
+		touch(group.main)
+		touch(group.backup)
+	}
+	return nil
+}
+
+// Failover runs the failover action to make the standby device active.
+func (group *RedundancyGroup) Failover(ctx context.Context) error {
+	group.mutex.RLock()
+	action := group.failoverAction
+	group.mutex.RUnlock()
+	if action == nil {
+		return fmt.Errorf("%w: %s", ErrControlNotSupported, DeviceControl_FAILOVER)
+	}
+	standby := group.GetPartner(group.GetActive())
+	if err := action(ctx, group, standby); err != nil {
+		return err
+	}
+	return group.SetActive(standby.GetId())
+}
+
+// Healthy reports whether both devices are online, i.e. the pair survives
+// the loss of one device.
+func (group *RedundancyGroup) Healthy() bool {
+	return group.main.GetStatus().ONLINE() && group.backup.GetStatus().ONLINE()
+}
+
+// Status returns the state of the pair as seen by device.
+func (group *RedundancyGroup) Status(device Device) *RedundancyStatus {
+	partner := group.GetPartner(device)
+	if partner == nil {
+		return nil
+	}
+	role := RedundancyRole_MAIN
+	if partner == group.main {
+		role = RedundancyRole_BACKUP
+	}
+	return &RedundancyStatus{
+		GroupId: group.id,
+		Role:    role,
+		Partner: partner.GetId(),
+		Active:  group.GetActive() != partner,
+		Healthy: group.Healthy(),
+	}
+}
+
+// CheckRedundancy raises "redundancy lost" on a device of an unhealthy pair
+// whose partner is down. The service checks it for every device.
+func CheckRedundancy(device *DeviceUpdate) *Error {
+	if device.Redundancy == nil || device.Redundancy.Healthy || !device.Status.ONLINE() {
+		return nil
+	}
+	return &Error{
+		Severity: PubErrorSeverity_HIGHEST,
+		Message:  fmt.Sprintf("redundancy lost, partner %s of %s is down", device.Redundancy.Partner, device.Redundancy.GroupId),
+	}
+}
+
+// touch makes the next update of device report its redundancy state. It only
+// touches atomics, so it is safe to call while the partner is locked.
+func touch(device Device) {
+	if impl, ok := device.(*deviceImpl); ok {
+		impl.modified.Store(true)
+		impl.notify()
+	}
+}
+
+func (device *deviceImpl) SetRedundancyGroup(group *RedundancyGroup) {
+	device.redundancy.Store(group)
+	touch(device)
+}
+
+func (device *deviceImpl) GetRedundancyGroup() *RedundancyGroup {
+	return device.redundancy.Load()
+}
+
+// redundancyStatus must be called without holding the lock, as it reads the
+// status of both devices.
+func (device *deviceImpl) redundancyStatus() *RedundancyStatus {
+	if group := device.redundancy.Load(); group != nil {
+		return group.Status(device)
+	}
+	return nil
+}
+
+// statusChanged updates the partner of a redundancy group, whose pair state
+// depends on the status of this device. It is called with the write lock
+// held, so it must not lock device.
+func (device *deviceImpl) statusChanged() {
+	if group := device.redundancy.Load(); group != nil {
+		if partner := group.GetPartner(device); partner != nil {
+			touch(partner)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRedundancyGroup(t *testing.T) {
+	main := NewDevice("1", DeviceType__GENERIC_DUMMY, "Main")
+	backup := NewDevice("2", DeviceType__GENERIC_DUMMY, "Backup")
+	main.SetStatus(DeviceStatus_ONLINE)
+	backup.SetStatus(DeviceStatus_ONLINE)
+	group := NewRedundancyGroup("pair", main, backup)
+
+	update := main.Updated()
+	expected := RedundancyStatus{GroupId: "pair", Role: RedundancyRole_MAIN, Partner: "2", Active: true, Healthy: true}
+	if update == nil || update.Redundancy == nil || *update.Redundancy != expected {
+		t.Fatalf("expected %+v, got %+v", expected, update)
+	}
+	if CheckRedundancy(update) != nil {
+		t.Error("healthy pair should not be an error")
+	}
+	backup.Updated()
+
+	backup.SetStatus(0)
+	update = main.Updated()
+	if update == nil || update.Redundancy.Healthy {
+		t.Fatalf("partner going offline should update the main device, got %+v", update)
+	}
+	if deviceError := CheckRedundancy(update); deviceError == nil || deviceError.Severity != PubErrorSeverity_HIGHEST {
+		t.Errorf("expected redundancy lost, got %+v", deviceError)
+	}
+	if update := backup.Updated(); update == nil || CheckRedundancy(update) != nil {
+		t.Errorf("offline device should not report redundancy lost, got %+v", update)
+	}
+
+	if err := main.FireAction(context.Background(), DeviceControl_FAILOVER); !errors.Is(err, ErrControlNotSupported) {
+		t.Errorf("failover without action should not be supported, got %v", err)
+	}
+	var switched Device
+	group.SetFailoverAction(func(ctx context.Context, group *RedundancyGroup, active Device) error {
+		switched = active
+		return nil
+	})
+	if err := backup.FireAction(context.Background(), DeviceControl_FAILOVER); err != nil {
+		t.Fatal(err)
+	}
+	if switched != backup || group.GetActive() != backup {
+		t.Errorf("failover should switch to the backup, got %v", switched)
+	}
+	update = main.Updated()
+	if update == nil || update.Redundancy.Active {
+		t.Errorf("main should not be active anymore, got %+v", update)
+	}
+	if update := backup.Updated(); update == nil || !update.Redundancy.Active || update.Redundancy.Role != RedundancyRole_BACKUP {
+		t.Errorf("backup should be active, got %+v", update)
+	}
+
+	if err := group.SetActive("3"); err == nil {
+		t.Error("unknown device should not become active")
+	}
+	if main.GetRedundancyGroup() != group || group.GetPartner(NewDevice("1", DeviceType__GENERIC_DUMMY, "Other")) != nil {
+		t.Error("partner should be looked up by device, not by id")
+	}
+}
@@ -0,0 +1,147 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// IOletAddress identifies an iolet across all devices.
+type IOletAddress struct {
+	DeviceId DeviceId `json:"deviceId"`
+	ModuleId ModuleId `json:"moduleId"`
+	IOletId  IOletId  `json:"ioletId"`
+}
+
+func (address IOletAddress) IsZero() bool {
+	return address == IOletAddress{}
+}
+
+func (address IOletAddress) String() string {
+	return fmt.Sprintf("%s/%s/%s", address.DeviceId, address.ModuleId, address.IOletId)
+}
+
+// Route feeds the signal of Source into Destination. Routes within one device
+// are crosspoints, routes between devices are links. A route is owned by the
+// device of its destination, since a destination has at most one source.
+type Route struct {
+	Source      IOletAddress `json:"source"`
+	Destination IOletAddress `json:"destination"`
+}
+
+// Crosspoint reports whether source and destination are on the same device.
+func (route Route) Crosspoint() bool {
+	return route.Source.DeviceId == route.Destination.DeviceId
+}
+
+// Connected reports whether the route has a source. A route without source
+// disconnects its destination.
+func (route Route) Connected() bool {
+	return !route.Source.IsZero()
+}
+
+// RouteAction changes the routing of a device. A route without source asks
+// the device to disconnect the destination.
+type RouteAction func(ctx context.Context, device Device, route Route) error
+
+func (device *deviceImpl) SetRouteAction(action RouteAction) {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+	device.routeAction = action
+}
+
+// CanRoute reports whether a route action is registered.
+func (device *deviceImpl) CanRoute() bool {
+	device.mutex.RLock()
+	defer device.mutex.RUnlock()
+	return device.routeAction != nil
+}
+
+// FireRouteAction runs the route action. Like FireAction it is called without
+// holding the device lock.
+func (device *deviceImpl) FireRouteAction(ctx context.Context, route Route) error {
+	device.mutex.RLock()
+	action := device.routeAction
+	deviceId := device.Id
+	device.mutex.RUnlock()
+	if action == nil {
+		return fmt.Errorf("%w: routing", ErrControlNotSupported)
+	}
+	if route.Destination.DeviceId != deviceId {
+		return fmt.Errorf("destination %s is not on device %s", route.Destination, deviceId)
+	}
+	return action(ctx, device, route)
+}
+
+func (device *deviceImpl) SetRoute(route Route) {
+	if !route.Connected() {
+		device.ClearRoute(route.Destination)
+		return
+	}
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+	index := device.indexOfRoute(route.Destination)
+	if index < 0 {
+		device.Routes = append(device.Routes, route)
+	} else if device.Routes[index] == route {
+		return
+	} else {
+		device.Routes[index] = route
+	}
+	device.markRouteChanged(route.Destination)
+}
+
+func (device *deviceImpl) ClearRoute(destination IOletAddress) {
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+	index := device.indexOfRoute(destination)
+	if index < 0 {
+		return
+	}
+	device.Routes = slices.Delete(device.Routes, index, index+1)
+	device.markRouteChanged(destination)
+}
+
+func (device *deviceImpl) GetRoutes() []Route {
+	device.mutex.RLock()
+	defer device.mutex.RUnlock()
+	return append([]Route(nil), device.Routes...)
+}
+
+func (device *deviceImpl) GetRoute(destination IOletAddress) (Route, bool) {
+	device.mutex.RLock()
+	defer device.mutex.RUnlock()
+	index := device.indexOfRoute(destination)
+	if index < 0 {
+		return Route{}, false
+	}
+	return device.Routes[index], true
+}
+
+// indexOfRoute expects the caller to hold the lock.
+func (device *deviceImpl) indexOfRoute(destination IOletAddress) int {
+	return slices.IndexFunc(device.Routes, func(route Route) bool { return route.Destination == destination })
+}
+
+// markRouteChanged expects the caller to hold the write lock.
+func (device *deviceImpl) markRouteChanged(destination IOletAddress) {
+	if !slices.Contains(device.changedRoutes, destination) {
+		device.changedRoutes = append(device.changedRoutes, destination)
+	}
+	device.notify()
+}
+
+// routeUpdates splits the changed destinations into current routes and
+// cleared destinations. It expects the caller to hold the lock.
+func (device *deviceImpl) routeUpdates(changed []IOletAddress) ([]Route, []IOletAddress) {
+	var routes []Route
+	var cleared []IOletAddress
+	for _, destination := range changed {
+		if index := device.indexOfRoute(destination); index >= 0 {
+			routes = append(routes, device.Routes[index])
+		} else {
+			cleared = append(cleared, destination)
+		}
+	}
+	return routes, cleared
+}
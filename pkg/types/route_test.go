@@ -0,0 +1,92 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDeviceRoutes(t *testing.T) {
+	device := NewDevice("A", DeviceType_RIEDEL_FUSION, "Frame")
+	input := IOletAddress{DeviceId: "A", ModuleId: "1", IOletId: "1"}
+	output := IOletAddress{DeviceId: "A", ModuleId: "1", IOletId: "5"}
+	remote := IOletAddress{DeviceId: "B", ModuleId: "2", IOletId: "3"}
+
+	device.SetRoute(Route{Source: input, Destination: output})
+	device.SetRoute(Route{Source: remote, Destination: input})
+	if routes := device.GetRoutes(); len(routes) != 2 {
+		t.Fatalf("expected 2 routes, got %v", routes)
+	}
+	route, ok := device.GetRoute(output)
+	if !ok || route.Source != input || !route.Crosspoint() {
+		t.Errorf("unexpected crosspoint %v", route)
+	}
+	if route, _ := device.GetRoute(input); route.Crosspoint() {
+		t.Error("route from another device should be a link")
+	}
+
+	update := device.Updated()
+	if update == nil || len(update.Routes) != 2 || len(update.ClearedRoutes) != 0 {
+		t.Fatalf("new routes should be reported: %+v", update)
+	}
+
+	device.SetRoute(Route{Source: input, Destination: output})
+	if device.Updated() != nil {
+		t.Error("unchanged route should not be reported")
+	}
+
+	device.SetRoute(Route{Destination: output})
+	update = device.Updated()
+	if update == nil || len(update.ClearedRoutes) != 1 || update.ClearedRoutes[0] != output {
+		t.Fatalf("cleared route should be reported: %+v", update)
+	}
+	if _, ok := device.GetRoute(output); ok {
+		t.Error("route should be cleared")
+	}
+
+	var buffer bytes.Buffer
+	if err := DeviceToJSON(json.NewEncoder(&buffer), device); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DeviceFromJSON(json.NewDecoder(&buffer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if routes := decoded.GetRoutes(); len(routes) != 1 || routes[0].Source != remote {
+		t.Errorf("routes should be restored: %v", routes)
+	}
+}
+
+func TestDeviceRouteAction(t *testing.T) {
+	device := NewDevice("A", DeviceType_RIEDEL_FUSION, "Frame")
+	destination := IOletAddress{DeviceId: "A", ModuleId: "1", IOletId: "5"}
+	source := IOletAddress{DeviceId: "A", ModuleId: "1", IOletId: "1"}
+
+	if err := device.FireRouteAction(context.Background(), Route{Source: source, Destination: destination}); !errors.Is(err, ErrControlNotSupported) {
+		t.Errorf("device without route action should not support routing, got %v", err)
+	}
+
+	device.SetRouteAction(func(ctx context.Context, device Device, route Route) error {
+		device.SetRoute(route)
+		return nil
+	})
+	if err := device.FireRouteAction(context.Background(), Route{Source: source, Destination: destination}); err != nil {
+		t.Fatal(err)
+	}
+	if route, ok := device.GetRoute(destination); !ok || route.Source != source {
+		t.Error("route action should set the route")
+	}
+	if err := device.FireRouteAction(context.Background(), Route{Source: source, Destination: IOletAddress{DeviceId: "B"}}); err == nil {
+		t.Error("destination on another device should be rejected")
+	}
+
+	body, err := json.Marshal(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(body, []byte(`"routing":true`)) {
+		t.Errorf("routing should be advertised: %s", body)
+	}
+}
@@ -0,0 +1,346 @@
+package types
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseSDP parses a session description of an ST 2110 / RFC 4566 stream.
+// Unknown attributes of the session and of each media description are kept,
+// so SDP() reproduces them.
+func ParseSDP(sdp string) (*StreamDescriptor, error) {
+	stream := &StreamDescriptor{Media: make([]MediaStream, 0)}
+	var media *MediaStream
+	var sessionDestination string
+	var sessionTTL int
+	var sessionPTP *PTPReference
+	var sessionMediaClock string
+
+	scanner := bufio.NewScanner(strings.NewReader(sdp))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if len(line) < 2 || line[1] != '=' {
+			return nil, fmt.Errorf("invalid sdp line %q", line)
+		}
+		value := line[2:]
+
+		switch line[0] {
+		case 's':
+			stream.SessionName = value
+		case 'o':
+			fields := strings.Fields(value)
+			if len(fields) == 6 {
+				stream.SessionId, stream.SessionVersion = fields[1], fields[2]
+				stream.Origin = fields[5]
+			}
+		case 'm':
+			parsed, err := parseMediaLine(value)
+			if err != nil {
+				return nil, err
+			}
+			parsed.Destination = sessionDestination
+			parsed.TTL = sessionTTL
+			parsed.PTP = sessionPTP
+			parsed.MediaClock = sessionMediaClock
+			stream.Media = append(stream.Media, parsed)
+			media = &stream.Media[len(stream.Media)-1]
+		case 'c':
+			destination, ttl, err := parseConnection(value)
+			if err != nil {
+				return nil, err
+			}
+			if media == nil {
+				sessionDestination, sessionTTL = destination, ttl
+			} else {
+				media.Destination, media.TTL = destination, ttl
+			}
+		case 'a':
+			if media == nil {
+				// session level attributes which apply to all media
+				name, attributeValue, _ := strings.Cut(value, ":")
+				switch name {
+				case "ts-refclk":
+					if reference := parseRefClock(attributeValue); reference != nil {
+						sessionPTP = reference
+						continue
+					}
+				case "mediaclk":
+					sessionMediaClock = attributeValue
+					continue
+				}
+				stream.Attributes = append(stream.Attributes, value)
+				continue
+			}
+			if err := media.parseAttribute(value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(stream.Media) == 0 {
+		return nil, fmt.Errorf("sdp has no media description")
+	}
+	return stream, nil
+}
+
+func parseMediaLine(value string) (MediaStream, error) {
+	// video 50000 RTP/AVP 96
+	fields := strings.Fields(value)
+	if len(fields) < 4 {
+		return MediaStream{}, fmt.Errorf("invalid media line %q", value)
+	}
+	port, err := strconv.Atoi(strings.Split(fields[1], "/")[0])
+	if err != nil {
+		return MediaStream{}, fmt.Errorf("invalid port in media line %q", value)
+	}
+	payloadType, err := strconv.Atoi(fields[3])
+	if err != nil {
+		return MediaStream{}, fmt.Errorf("invalid payload type in media line %q", value)
+	}
+	return MediaStream{MediaType: fields[0], Port: port, PayloadType: payloadType}, nil
+}
+
+func parseConnection(value string) (string, int, error) {
+	// IN IP4 239.100.1.1/64
+	fields := strings.Fields(value)
+	if len(fields) != 3 {
+		return "", 0, fmt.Errorf("invalid connection line %q", value)
+	}
+	address, ttl, found := strings.Cut(fields[2], "/")
+	if !found {
+		return address, 0, nil
+	}
+	parsedTTL, err := strconv.Atoi(ttl)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid ttl in connection line %q", value)
+	}
+	return address, parsedTTL, nil
+}
+
+func parseRefClock(value string) *PTPReference {
+	// ptp=IEEE1588-2008:08-00-11-FF-FE-21-E1-B0:0 or ptp=IEEE1588-2008:traceable
+	clock, found := strings.CutPrefix(value, "ptp=")
+	if !found {
+		return nil
+	}
+	parts := strings.Split(clock, ":")
+	reference := &PTPReference{Version: parts[0]}
+	if len(parts) > 1 && parts[1] == "traceable" {
+		reference.Traceable = true
+		return reference
+	}
+	if len(parts) > 1 {
+		reference.Grandmaster = parts[1]
+	}
+	if len(parts) > 2 {
+		reference.Domain, _ = strconv.Atoi(parts[2])
+	}
+	return reference
+}
+
+func (media *MediaStream) parseAttribute(attribute string) error {
+	name, value, _ := strings.Cut(attribute, ":")
+	switch name {
+	case "rtpmap":
+		// 96 raw/90000 or 97 L24/48000/8
+		_, encoding, _ := strings.Cut(value, " ")
+		parts := strings.Split(encoding, "/")
+		media.Encoding = parts[0]
+		if len(parts) > 1 {
+			clockRate, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return fmt.Errorf("invalid clock rate in %q", attribute)
+			}
+			media.ClockRate = clockRate
+		}
+		if len(parts) > 2 {
+			channels, err := strconv.Atoi(parts[2])
+			if err != nil {
+				return fmt.Errorf("invalid channel count in %q", attribute)
+			}
+			media.Channels = channels
+		}
+	case "fmtp":
+		_, parameters, _ := strings.Cut(value, " ")
+		return media.parseFormatParameters(parameters)
+	case "ptime":
+		packetTime, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid packet time in %q", attribute)
+		}
+		media.PacketTime = packetTime
+	case "source-filter":
+		// incl IN IP4 239.100.1.1 192.168.1.10
+		fields := strings.Fields(value)
+		if len(fields) >= 5 {
+			media.Source = fields[len(fields)-1]
+		}
+	case "ts-refclk":
+		if reference := parseRefClock(value); reference != nil {
+			media.PTP = reference
+			return nil
+		}
+		media.Attributes = append(media.Attributes, attribute)
+	case "mediaclk":
+		media.MediaClock = value
+	default:
+		media.Attributes = append(media.Attributes, attribute)
+	}
+	return nil
+}
+
+func (media *MediaStream) parseFormatParameters(parameters string) error {
+	for _, parameter := range strings.Split(parameters, ";") {
+		parameter = strings.TrimSpace(parameter)
+		if parameter == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(parameter, "=")
+		var err error
+		switch key {
+		case "width":
+			media.Width, err = strconv.Atoi(value)
+		case "height":
+			media.Height, err = strconv.Atoi(value)
+		case "depth":
+			media.Depth, err = strconv.Atoi(value)
+		case "exactframerate":
+			media.FrameRate = value
+		case "sampling":
+			media.Sampling = value
+		case "colorimetry":
+			media.Colorimetry = value
+		case "interlace":
+			media.Interlaced = true
+		default:
+			if media.FormatParameters == nil {
+				media.FormatParameters = make(map[string]string)
+			}
+			media.FormatParameters[key] = value
+		}
+		if err != nil {
+			return fmt.Errorf("invalid format parameter %q", parameter)
+		}
+	}
+	return nil
+}
+
+// SDP generates the session description of the stream.
+func (stream *StreamDescriptor) SDP() string {
+	var builder strings.Builder
+	origin := stream.Origin
+	if origin == "" {
+		origin = "0.0.0.0"
+	}
+	sessionId := stream.SessionId
+	if sessionId == "" {
+		sessionId = "0"
+	}
+	sessionVersion := stream.SessionVersion
+	if sessionVersion == "" {
+		sessionVersion = "0"
+	}
+	builder.WriteString("v=0\r\n")
+	fmt.Fprintf(&builder, "o=- %s %s IN %s %s\r\n", sessionId, sessionVersion, addressType(origin), origin)
+	sessionName := stream.SessionName
+	if sessionName == "" {
+		sessionName = "-"
+	}
+	fmt.Fprintf(&builder, "s=%s\r\n", sessionName)
+	builder.WriteString("t=0 0\r\n")
+	for _, attribute := range stream.Attributes {
+		fmt.Fprintf(&builder, "a=%s\r\n", attribute)
+	}
+
+	for _, media := range stream.Media {
+		media.writeSDP(&builder)
+	}
+	return builder.String()
+}
+
+func (media MediaStream) writeSDP(builder *strings.Builder) {
+	fmt.Fprintf(builder, "m=%s %d RTP/AVP %d\r\n", media.MediaType, media.Port, media.PayloadType)
+	// without a destination there is no valid connection line, e.g. for a
+	// sender which is not configured yet
+	if media.Destination != "" && media.TTL > 0 {
+		fmt.Fprintf(builder, "c=IN %s %s/%d\r\n", addressType(media.Destination), media.Destination, media.TTL)
+	} else if media.Destination != "" {
+		fmt.Fprintf(builder, "c=IN %s %s\r\n", addressType(media.Destination), media.Destination)
+	}
+	if media.Source != "" && media.Destination != "" {
+		fmt.Fprintf(builder, "a=source-filter: incl IN %s %s %s\r\n", addressType(media.Destination), media.Destination, media.Source)
+	}
+	if media.Channels > 0 {
+		fmt.Fprintf(builder, "a=rtpmap:%d %s/%d/%d\r\n", media.PayloadType, media.Encoding, media.ClockRate, media.Channels)
+	} else {
+		fmt.Fprintf(builder, "a=rtpmap:%d %s/%d\r\n", media.PayloadType, media.Encoding, media.ClockRate)
+	}
+	if parameters := media.formatParameters(); len(parameters) > 0 {
+		fmt.Fprintf(builder, "a=fmtp:%d %s\r\n", media.PayloadType, strings.Join(parameters, "; "))
+	}
+	if media.PacketTime > 0 {
+		fmt.Fprintf(builder, "a=ptime:%s\r\n", strconv.FormatFloat(media.PacketTime, 'f', -1, 64))
+	}
+	if media.PTP != nil {
+		fmt.Fprintf(builder, "a=ts-refclk:ptp=%s\r\n", media.PTP)
+	}
+	if media.MediaClock != "" {
+		fmt.Fprintf(builder, "a=mediaclk:%s\r\n", media.MediaClock)
+	}
+	for _, attribute := range media.Attributes {
+		fmt.Fprintf(builder, "a=%s\r\n", attribute)
+	}
+}
+
+func (media MediaStream) formatParameters() []string {
+	parameters := make([]string, 0)
+	if media.Sampling != "" {
+		parameters = append(parameters, "sampling="+media.Sampling)
+	}
+	if media.Width > 0 {
+		parameters = append(parameters, fmt.Sprintf("width=%d", media.Width))
+	}
+	if media.Height > 0 {
+		parameters = append(parameters, fmt.Sprintf("height=%d", media.Height))
+	}
+	if media.FrameRate != "" {
+		parameters = append(parameters, "exactframerate="+media.FrameRate)
+	}
+	if media.Depth > 0 {
+		parameters = append(parameters, fmt.Sprintf("depth=%d", media.Depth))
+	}
+	if media.Colorimetry != "" {
+		parameters = append(parameters, "colorimetry="+media.Colorimetry)
+	}
+	if media.Interlaced {
+		parameters = append(parameters, "interlace")
+	}
+	keys := make([]string, 0, len(media.FormatParameters))
+	for key := range media.FormatParameters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if value := media.FormatParameters[key]; value != "" {
+			parameters = append(parameters, key+"="+value)
+		} else {
+			parameters = append(parameters, key)
+		}
+	}
+	return parameters
+}
+
+func addressType(address string) string {
+	if strings.Contains(address, ":") {
+		return "IP6"
+	}
+	return "IP4"
+}
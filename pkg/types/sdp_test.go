@@ -0,0 +1,257 @@
+package types
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const videoSDP = `v=0
+o=- 1443716955 1443716955 IN IP4 192.168.1.10
+s=Camera 1 Video
+t=0 0
+m=video 50000 RTP/AVP 96
+c=IN IP4 239.100.1.1/64
+a=source-filter: incl IN IP4 239.100.1.1 192.168.1.10
+a=rtpmap:96 raw/90000
+a=fmtp:96 sampling=YCbCr-4:2:2; width=1920; height=1080; exactframerate=30000/1001; depth=10; TCS=SDR; colorimetry=BT709; PM=2110GPM; SSN=ST2110-20:2017; TP=2110TPN; interlace
+a=ts-refclk:ptp=IEEE1588-2008:08-00-11-FF-FE-21-E1-B0:127
+a=mediaclk:direct=0
+a=mid:primary
+`
+
+const audioSDP = `v=0
+o=- 1 1 IN IP4 192.168.1.20
+s=Mic 1
+t=0 0
+m=audio 5004 RTP/AVP 97
+c=IN IP4 239.100.2.1/32
+a=rtpmap:97 L24/48000/8
+a=ptime:0.125
+a=ts-refclk:ptp=IEEE1588-2008:traceable
+a=mediaclk:direct=0
+`
+
+// redundantSDP describes an ST 2022-7 stream with PTP on session level.
+const redundantSDP = `v=0
+o=- 2 2 IN IP4 192.168.1.30
+s=Playout 1
+t=0 0
+a=group:DUP primary secondary
+a=ts-refclk:ptp=IEEE1588-2008:08-00-11-FF-FE-21-E1-B0:127
+a=mediaclk:direct=0
+m=video 50000 RTP/AVP 96
+c=IN IP4 239.100.1.1/64
+a=rtpmap:96 raw/90000
+a=fmtp:96 sampling=YCbCr-4:2:2; width=1280; height=720; exactframerate=50; depth=10; colorimetry=BT709
+a=mid:primary
+m=video 50000 RTP/AVP 96
+c=IN IP4 239.200.1.1/64
+a=rtpmap:96 raw/90000
+a=fmtp:96 sampling=YCbCr-4:2:2; width=1280; height=720; exactframerate=50; depth=10; colorimetry=BT709
+a=mid:secondary
+`
+
+func TestParseVideoSDP(t *testing.T) {
+	stream, err := ParseSDP(videoSDP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stream.SessionName != "Camera 1 Video" || stream.Origin != "192.168.1.10" || len(stream.Media) != 1 {
+		t.Fatalf("unexpected stream %+v", stream)
+	}
+	media := stream.Media[0]
+	if media.MediaType != "video" || media.Destination != "239.100.1.1" || media.TTL != 64 || media.Port != 50000 || !media.Multicast() {
+		t.Errorf("unexpected transport %+v", media)
+	}
+	if media.Source != "192.168.1.10" {
+		t.Errorf("source should be taken from the source filter, got %s", media.Source)
+	}
+	if media.Encoding != "raw" || media.ClockRate != 90000 || media.PayloadType != 96 {
+		t.Errorf("unexpected rtpmap %+v", media)
+	}
+	if media.Width != 1920 || media.Height != 1080 || media.FrameRate != "30000/1001" || media.Depth != 10 || media.Sampling != "YCbCr-4:2:2" || media.Colorimetry != "BT709" || !media.Interlaced {
+		t.Errorf("unexpected video format %+v", media)
+	}
+	if media.FormatParameters["TCS"] != "SDR" || media.FormatParameters["PM"] != "2110GPM" || len(media.FormatParameters) != 4 {
+		t.Errorf("unexpected format parameters %v", media.FormatParameters)
+	}
+	if media.PTP == nil || media.PTP.Grandmaster != "08-00-11-FF-FE-21-E1-B0" || media.PTP.Domain != 127 || media.MediaClock != "direct=0" {
+		t.Errorf("unexpected timing %+v %s", media.PTP, media.MediaClock)
+	}
+	if len(media.Attributes) != 1 || media.Attributes[0] != "mid:primary" {
+		t.Errorf("unknown attributes should be kept, got %v", media.Attributes)
+	}
+}
+
+func TestParseAudioSDP(t *testing.T) {
+	stream, err := ParseSDP(audioSDP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	media := stream.Media[0]
+	if media.MediaType != "audio" || media.Encoding != "L24" || media.ClockRate != 48000 || media.Channels != 8 || media.PacketTime != 0.125 {
+		t.Errorf("unexpected audio format %+v", media)
+	}
+	if media.PTP == nil || !media.PTP.Traceable {
+		t.Errorf("expected traceable reference clock, got %+v", media.PTP)
+	}
+}
+
+func TestParseRedundantSDP(t *testing.T) {
+	stream, err := ParseSDP(redundantSDP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stream.Media) != 2 {
+		t.Fatalf("expected two legs, got %d", len(stream.Media))
+	}
+	if stream.Media[0].Destination != "239.100.1.1" || stream.Media[1].Destination != "239.200.1.1" {
+		t.Errorf("unexpected destinations %s %s", stream.Media[0].Destination, stream.Media[1].Destination)
+	}
+	for _, media := range stream.Media {
+		if media.PTP == nil || media.PTP.Domain != 127 || media.MediaClock != "direct=0" {
+			t.Errorf("session level timing should apply to every leg, got %+v", media)
+		}
+	}
+}
+
+func TestGenerateSDP(t *testing.T) {
+	for _, sdp := range []string{videoSDP, audioSDP, redundantSDP} {
+		stream, err := ParseSDP(sdp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		generated := stream.SDP()
+		if !strings.HasPrefix(generated, "v=0\r\n") || !strings.Contains(generated, "\r\nt=0 0\r\n") {
+			t.Errorf("generated sdp lacks session lines:\n%s", generated)
+		}
+		reparsed, err := ParseSDP(generated)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reparsed.SDP() != generated {
+			t.Errorf("generated sdp does not round trip:\n%s\n%s", generated, reparsed.SDP())
+		}
+		expected, _ := json.Marshal(stream)
+		actual, _ := json.Marshal(reparsed)
+		if string(expected) != string(actual) {
+			t.Errorf("reparsed stream differs:\n%s\n%s", expected, actual)
+		}
+	}
+
+	stream, _ := ParseSDP(videoSDP)
+	generated := stream.SDP()
+	if !strings.Contains(generated, "a=fmtp:96 sampling=YCbCr-4:2:2; width=1920; height=1080; exactframerate=30000/1001; depth=10; colorimetry=BT709; interlace; PM=2110GPM; SSN=ST2110-20:2017; TCS=SDR; TP=2110TPN\r\n") {
+		t.Errorf("unexpected fmtp line:\n%s", generated)
+	}
+	if !strings.Contains(generated, "a=source-filter: incl IN IP4 239.100.1.1 192.168.1.10\r\n") {
+		t.Errorf("missing source filter:\n%s", generated)
+	}
+}
+
+func TestRedundantSDPRoundTrip(t *testing.T) {
+	stream, err := ParseSDP(redundantSDP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stream.Attributes) != 1 || stream.Attributes[0] != "group:DUP primary secondary" {
+		t.Errorf("session attributes should be kept, got %v", stream.Attributes)
+	}
+	if stream.SessionId != "2" || stream.SessionVersion != "2" {
+		t.Errorf("origin session id and version should be kept, got %s %s", stream.SessionId, stream.SessionVersion)
+	}
+	generated := stream.SDP()
+	if !strings.HasPrefix(generated, "v=0\r\no=- 2 2 IN IP4 192.168.1.30\r\n") {
+		t.Errorf("unexpected origin:\n%s", generated)
+	}
+	if !strings.Contains(generated, "\r\nt=0 0\r\na=group:DUP primary secondary\r\nm=video") {
+		t.Errorf("group should be written on session level:\n%s", generated)
+	}
+	for _, mid := range []string{"a=mid:primary\r\n", "a=mid:secondary\r\n"} {
+		if !strings.Contains(generated, mid) {
+			t.Errorf("missing %s in:\n%s", strings.TrimSpace(mid), generated)
+		}
+	}
+
+	clone := stream.Clone()
+	clone.Attributes[0] = "group:DUP a b"
+	if stream.Attributes[0] != "group:DUP primary secondary" {
+		t.Error("clone should not share the session attributes")
+	}
+}
+
+func TestGenerateSDPWithoutDestination(t *testing.T) {
+	stream := &StreamDescriptor{Media: []MediaStream{{MediaType: "video", Port: 5000, PayloadType: 96, Encoding: "raw", ClockRate: 90000, Source: "192.168.1.10"}}}
+	generated := stream.SDP()
+	if strings.Contains(generated, "c=") || strings.Contains(generated, "source-filter") {
+		t.Errorf("sdp without destination should have no connection line:\n%s", generated)
+	}
+	if _, err := ParseSDP(generated); err != nil {
+		t.Errorf("generated sdp should parse: %v", err)
+	}
+}
+
+func TestParseInvalidSDP(t *testing.T) {
+	invalid := []string{
+		"v=0\ns=no media\n",
+		"v=0\nm=video port RTP/AVP 96\n",
+		"v=0\nm=video 5000 RTP/AVP 96\nc=IN IP4 239.1.1.1/ttl\n",
+		"v=0\nm=video 5000 RTP/AVP 96\na=fmtp:96 width=wide\n",
+		"garbage\n",
+	}
+	for _, sdp := range invalid {
+		if _, err := ParseSDP(sdp); err == nil {
+			t.Errorf("sdp %q should be rejected", sdp)
+		}
+	}
+}
+
+func TestIOletStream(t *testing.T) {
+	iolet := NewIOlet("1", IOletType_IPVIDEOOUT, "Video Out")
+	streamer, ok := iolet.(Streamer)
+	if !ok {
+		t.Fatal("iolets of NewIOlet should be streamers")
+	}
+	iolet.Updated()
+	if streamer.GetStream() != nil {
+		t.Error("new iolet should have no stream")
+	}
+
+	stream, err := ParseSDP(videoSDP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamer.SetStream(stream)
+	update := iolet.Updated()
+	if update == nil || update.Stream == nil || update.Stream.Media[0].Width != 1920 {
+		t.Fatalf("update should carry the new stream, got %+v", update)
+	}
+
+	stream.Media[0].Width = 1280
+	if streamer.GetStream().Media[0].Width != 1920 {
+		t.Error("iolet should keep a copy of the stream")
+	}
+
+	iolet.SetStatus(IOletStatus_RUNNING)
+	if update := iolet.Updated(); update == nil || update.Stream != nil {
+		t.Errorf("status update should not repeat the stream, got %+v", update)
+	}
+
+	streamer.SetStream(streamer.GetStream())
+	if iolet.Updated() != nil {
+		t.Error("setting the same stream should not modify the iolet")
+	}
+
+	body, err := json.Marshal(iolet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := IOletFromJSON(json.NewDecoder(strings.NewReader(string(body))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if StreamOf(decoded) == nil || StreamOf(decoded).SDP() != streamer.GetStream().SDP() {
+		t.Error("stream should survive json encoding")
+	}
+}
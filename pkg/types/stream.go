@@ -0,0 +1,153 @@
+package types
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+)
+
+// StreamDescriptor describes the RTP streams of an IP iolet as announced by
+// its SDP (RFC 4566, SMPTE ST 2110). A redundant ST 2022-7 stream has one
+// media description per leg.
+type StreamDescriptor struct {
+	SessionName string `json:"sessionName,omitempty"`
+	// Origin is the unicast address from the o= line
+	Origin string `json:"origin,omitempty"`
+	// SessionId and SessionVersion are taken from the o= line, so a
+	// generated SDP keeps the identity of the parsed one
+	SessionId      string `json:"sessionId,omitempty"`
+	SessionVersion string `json:"sessionVersion,omitempty"`
+	// Attributes holds the session level a= lines without a field, e.g. the
+	// group:DUP of an ST 2022-7 stream, without the leading "a="
+	Attributes []string      `json:"attributes,omitempty"`
+	Media      []MediaStream `json:"media"`
+}
+
+// MediaStream is one media description (m= section) of a stream.
+type MediaStream struct {
+	// MediaType is video, audio or data as given in the m= line
+	MediaType   string `json:"mediaType"`
+	Destination string `json:"destination"`
+	TTL         int    `json:"ttl,omitempty"`
+	// Source is the sender address from the source filter
+	Source      string `json:"source,omitempty"`
+	Port        int    `json:"port"`
+	PayloadType int    `json:"payloadType"`
+	// Encoding is the rtpmap encoding, e.g. raw, L24 or smpte291
+	Encoding string `json:"encoding"`
+	// ClockRate is the RTP clock rate, which is the sample rate for audio
+	ClockRate int `json:"clockRate"`
+
+	// video, ST 2110-20
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	FrameRate   string `json:"frameRate,omitempty"`
+	Sampling    string `json:"sampling,omitempty"`
+	Depth       int    `json:"depth,omitempty"`
+	Colorimetry string `json:"colorimetry,omitempty"`
+	Interlaced  bool   `json:"interlaced,omitempty"`
+
+	// audio, ST 2110-30
+	Channels int `json:"channels,omitempty"`
+	// PacketTime in milliseconds
+	PacketTime float64 `json:"packetTime,omitempty"`
+
+	// timing, ST 2110-10
+	PTP        *PTPReference `json:"ptp,omitempty"`
+	MediaClock string        `json:"mediaClock,omitempty"`
+
+	// FormatParameters holds the fmtp parameters without a field above
+	FormatParameters map[string]string `json:"formatParameters,omitempty"`
+	// Attributes holds all other a= lines of the media description, without
+	// the leading "a="
+	Attributes []string `json:"attributes,omitempty"`
+}
+
+// PTPReference is the PTP reference clock of a stream (RFC 7273 ts-refclk).
+type PTPReference struct {
+	// Version, e.g. IEEE1588-2008
+	Version string `json:"version"`
+	// Grandmaster is the EUI-64 clock identity, e.g. 08-00-11-FF-FE-21-E1-B0
+	Grandmaster string `json:"grandmaster,omitempty"`
+	Domain      int    `json:"domain"`
+	// Traceable is set when the stream only states a traceable time source
+	Traceable bool `json:"traceable,omitempty"`
+}
+
+func (reference PTPReference) String() string {
+	if reference.Traceable {
+		return fmt.Sprintf("%s:traceable", reference.Version)
+	}
+	return fmt.Sprintf("%s:%s:%d", reference.Version, reference.Grandmaster, reference.Domain)
+}
+
+// Multicast reports whether the destination is a multicast group.
+func (media MediaStream) Multicast() bool {
+	ip := net.ParseIP(media.Destination)
+	return ip != nil && ip.IsMulticast()
+}
+
+// Clone returns a deep copy of the descriptor.
+func (stream *StreamDescriptor) Clone() *StreamDescriptor {
+	if stream == nil {
+		return nil
+	}
+	clone := *stream
+	clone.Attributes = slices.Clone(stream.Attributes)
+	clone.Media = make([]MediaStream, len(stream.Media))
+	for index, media := range stream.Media {
+		if media.PTP != nil {
+			ptp := *media.PTP
+			media.PTP = &ptp
+		}
+		media.FormatParameters = maps.Clone(media.FormatParameters)
+		media.Attributes = slices.Clone(media.Attributes)
+		clone.Media[index] = media
+	}
+	return &clone
+}
+
+// IP reports whether iolets of this type carry IP streams.
+func (ioletType IOletType) IP() bool {
+	return strings.HasPrefix(string(ioletType), "IP-")
+}
+
+// Streamer is implemented by iolets which carry a stream descriptor, like
+// those of NewIOlet.
+type Streamer interface {
+	// SetStream sets the stream descriptor of an IP iolet, nil removes it.
+	SetStream(stream *StreamDescriptor)
+	// GetStream returns a copy of the stream descriptor, nil if there is none.
+	GetStream() *StreamDescriptor
+}
+
+// StreamOf returns the stream descriptor of iolet, nil if it has none or is
+// not a Streamer.
+func StreamOf(iolet IOlet) *StreamDescriptor {
+	if streamer, ok := iolet.(Streamer); ok {
+		return streamer.GetStream()
+	}
+	return nil
+}
+
+func (iolet *ioletImpl) SetStream(stream *StreamDescriptor) {
+	iolet.mutex.Lock()
+	defer iolet.mutex.Unlock()
+	if stream == nil && iolet.stream == nil {
+		return
+	}
+	if stream != nil && iolet.stream != nil && stream.SDP() == iolet.stream.SDP() {
+		return
+	}
+	iolet.stream = stream.Clone()
+	iolet.streamModified = true
+	iolet.markModified()
+}
+
+func (iolet *ioletImpl) GetStream() *StreamDescriptor {
+	iolet.mutex.RLock()
+	defer iolet.mutex.RUnlock()
+	return iolet.stream.Clone()
+}
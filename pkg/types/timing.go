@@ -0,0 +1,125 @@
+package types
+
+import (
+	"time"
+)
+
+// PTPPortState is the state of a PTP port (IEEE 1588-2019 naming).
+type PTPPortState string
+
+const (
+	PTPPortState_INITIALIZING PTPPortState = "initializing"
+	PTPPortState_FAULTY       PTPPortState = "faulty"
+	PTPPortState_DISABLED     PTPPortState = "disabled"
+	PTPPortState_LISTENING    PTPPortState = "listening"
+	PTPPortState_LEADER       PTPPortState = "leader"
+	PTPPortState_PASSIVE      PTPPortState = "passive"
+	PTPPortState_UNCALIBRATED PTPPortState = "uncalibrated"
+	PTPPortState_FOLLOWER     PTPPortState = "follower"
+)
+
+// PTPStatus is the PTP state of a timing iolet. Durations are encoded in
+// nanoseconds.
+type PTPStatus struct {
+	PortState PTPPortState `json:"portState"`
+	// Grandmaster is the clock identity of the grandmaster, e.g.
+	// 08-00-11-FF-FE-21-E1-B0
+	Grandmaster      string        `json:"grandmaster"`
+	Domain           int           `json:"domain"`
+	OffsetFromMaster time.Duration `json:"offsetFromMaster"`
+	MeanPathDelay    time.Duration `json:"meanPathDelay"`
+}
+
+func (status *PTPStatus) clone() *PTPStatus {
+	if status == nil {
+		return nil
+	}
+	clone := *status
+	return &clone
+}
+
+// PTPThresholds decide whether a PTP state is OK. Zero values are not
+// checked.
+type PTPThresholds struct {
+	// MaxOffset bounds the absolute offset from master
+	MaxOffset        time.Duration
+	MaxMeanPathDelay time.Duration
+	// Grandmaster is the expected grandmaster identity
+	Grandmaster string
+	// Leader accepts the leader state, e.g. for the grandmaster itself
+	Leader bool
+}
+
+// OK reports whether status is within the thresholds. Only synchronized
+// ports, i.e. followers and passive ports, are OK.
+func (thresholds PTPThresholds) OK(status PTPStatus) bool {
+	switch status.PortState {
+	case PTPPortState_FOLLOWER, PTPPortState_PASSIVE:
+	case PTPPortState_LEADER:
+		if !thresholds.Leader {
+			return false
+		}
+	default:
+		return false
+	}
+	if thresholds.Grandmaster != "" && status.Grandmaster != thresholds.Grandmaster {
+		return false
+	}
+	offset := status.OffsetFromMaster
+	if offset < 0 {
+		offset = -offset
+	}
+	if thresholds.MaxOffset > 0 && offset > thresholds.MaxOffset {
+		return false
+	}
+	if thresholds.MaxMeanPathDelay > 0 && status.MeanPathDelay > thresholds.MaxMeanPathDelay {
+		return false
+	}
+	return true
+}
+
+// Apply sets the PTP state of iolet, if it is a PTPReporter, and derives its
+// OK flag from the thresholds.
+func (thresholds PTPThresholds) Apply(iolet IOlet, status PTPStatus) {
+	if reporter, ok := iolet.(PTPReporter); ok {
+		reporter.SetPTPStatus(status)
+	}
+	iolet.ModifyStatus(func(ioletStatus *IOletStatus) {
+		ioletStatus.SetOK(thresholds.OK(status))
+	})
+}
+
+// PTPReporter is implemented by iolets which carry a PTP state, like those
+// of NewIOlet.
+type PTPReporter interface {
+	// SetPTPStatus sets the PTP state of a timing iolet.
+	SetPTPStatus(status PTPStatus)
+	// GetPTPStatus returns the PTP state, nil if there is none.
+	GetPTPStatus() *PTPStatus
+}
+
+// PTPStatusOf returns the PTP state of iolet, nil if it has none or is not a
+// PTPReporter.
+func PTPStatusOf(iolet IOlet) *PTPStatus {
+	if reporter, ok := iolet.(PTPReporter); ok {
+		return reporter.GetPTPStatus()
+	}
+	return nil
+}
+
+func (iolet *ioletImpl) SetPTPStatus(status PTPStatus) {
+	iolet.mutex.Lock()
+	defer iolet.mutex.Unlock()
+	if iolet.ptp != nil && *iolet.ptp == status {
+		return
+	}
+	iolet.ptp = &status
+	iolet.ptpModified = true
+	iolet.markModified()
+}
+
+func (iolet *ioletImpl) GetPTPStatus() *PTPStatus {
+	iolet.mutex.RLock()
+	defer iolet.mutex.RUnlock()
+	return iolet.ptp.clone()
+}
@@ -0,0 +1,92 @@
+package types
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPTPThresholds(t *testing.T) {
+	thresholds := PTPThresholds{MaxOffset: time.Microsecond, MaxMeanPathDelay: time.Millisecond, Grandmaster: "08-00-11-FF-FE-21-E1-B0"}
+	synchronized := PTPStatus{
+		PortState:        PTPPortState_FOLLOWER,
+		Grandmaster:      "08-00-11-FF-FE-21-E1-B0",
+		Domain:           127,
+		OffsetFromMaster: -200 * time.Nanosecond,
+		MeanPathDelay:    3 * time.Microsecond,
+	}
+	if !thresholds.OK(synchronized) {
+		t.Error("synchronized follower should be OK")
+	}
+
+	notOK := map[string]func(status *PTPStatus){
+		"listening":       func(status *PTPStatus) { status.PortState = PTPPortState_LISTENING },
+		"leader":          func(status *PTPStatus) { status.PortState = PTPPortState_LEADER },
+		"offset":          func(status *PTPStatus) { status.OffsetFromMaster = -2 * time.Microsecond },
+		"path delay":      func(status *PTPStatus) { status.MeanPathDelay = 2 * time.Millisecond },
+		"grandmaster":     func(status *PTPStatus) { status.Grandmaster = "00-00-00-FF-FE-00-00-01" },
+		"unknown state":   func(status *PTPStatus) { status.PortState = "" },
+		"uncalibrated":    func(status *PTPStatus) { status.PortState = PTPPortState_UNCALIBRATED },
+		"positive offset": func(status *PTPStatus) { status.OffsetFromMaster = 1001 * time.Nanosecond },
+	}
+	for name, modify := range notOK {
+		status := synchronized
+		modify(&status)
+		if thresholds.OK(status) {
+			t.Errorf("%s should not be OK", name)
+		}
+	}
+
+	thresholds.Leader = true
+	leader := synchronized
+	leader.PortState = PTPPortState_LEADER
+	if !thresholds.OK(leader) {
+		t.Error("leader should be OK if accepted")
+	}
+}
+
+func TestIOletPTPStatus(t *testing.T) {
+	iolet := NewIOlet("1", IOletType_IPTIMING, "PTP")
+	iolet.Updated()
+	thresholds := PTPThresholds{MaxOffset: time.Microsecond}
+
+	status := PTPStatus{PortState: PTPPortState_FOLLOWER, Grandmaster: "08-00-11-FF-FE-21-E1-B0", OffsetFromMaster: 100}
+	thresholds.Apply(iolet, status)
+	update := iolet.Updated()
+	if update == nil || update.PTP == nil || *update.PTP != status || !update.Status.OK() {
+		t.Fatalf("update should carry the PTP state, got %+v", update)
+	}
+
+	thresholds.Apply(iolet, status)
+	if iolet.Updated() != nil {
+		t.Error("same PTP state should not modify the iolet")
+	}
+
+	status.OffsetFromMaster = 5 * time.Microsecond
+	thresholds.Apply(iolet, status)
+	update = iolet.Updated()
+	if update == nil || update.PTP == nil || update.Status.OK() {
+		t.Errorf("offset above the threshold should be NOK, got %+v", update)
+	}
+
+	iolet.SetName("PTP Port 1")
+	if update := iolet.Updated(); update == nil || update.PTP != nil {
+		t.Errorf("name update should not repeat the PTP state, got %+v", update)
+	}
+
+	body, err := json.Marshal(iolet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), `"offsetFromMaster":5000`) {
+		t.Errorf("offset should be encoded in nanoseconds: %s", body)
+	}
+	decoded, err := IOletFromJSON(json.NewDecoder(strings.NewReader(string(body))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ptp := PTPStatusOf(decoded); ptp == nil || *ptp != status {
+		t.Errorf("PTP state should survive json encoding, got %+v", ptp)
+	}
+}
@@ -0,0 +1,157 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// RESERVED_PREFIX starts the paths the driver api serves next to the devices
+// and their controls, e.g. /_jobs or /{deviceId}/_routes. Device ids and device
+// controls must not start with it.
+const RESERVED_PREFIX = "_"
+
+// Problem is a structural problem of a device tree. Path locates the
+// element, e.g. device 1/module av/iolet 2.
+type Problem struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every problem found by Validate.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (err *ValidationError) Error() string {
+	problems := make([]string, 0, len(err.Problems))
+	for _, problem := range err.Problems {
+		problems = append(problems, fmt.Sprintf("%s: %s", problem.Path, problem.Message))
+	}
+	return fmt.Sprintf("invalid device tree: %s", strings.Join(problems, "; "))
+}
+
+// Validate checks the devices including their modules and iolets for empty
+// or duplicate ids, empty names and unknown types. It returns a
+// *ValidationError listing all problems, or nil.
+//
+// Duplicate ids make GetModule and GetIOlet return only the first match.
+func Validate(devices []Device) error {
+	validation := &ValidationError{}
+	deviceIds := make([]DeviceId, 0, len(devices))
+	for _, device := range devices {
+		snapshot := deviceTreeSnapshot(device)
+		path := fmt.Sprintf("device %s", snapshot.Id)
+		if slices.Contains(deviceIds, snapshot.Id) {
+			validation.add(path, "duplicate device id")
+		}
+		deviceIds = append(deviceIds, snapshot.Id)
+		validation.validateDevice(path, snapshot)
+	}
+	if len(validation.Problems) > 0 {
+		return validation
+	}
+	return nil
+}
+
+// ValidateDevice checks a single device, see Validate.
+func ValidateDevice(device Device) error {
+	return Validate([]Device{device})
+}
+
+func (validation *ValidationError) add(path string, format string, args ...any) {
+	validation.Problems = append(validation.Problems, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (validation *ValidationError) validateDevice(path string, device deviceSnapshot) {
+	if device.Id == "" {
+		validation.add(path, "empty id")
+	}
+	if strings.HasPrefix(string(device.Id), RESERVED_PREFIX) {
+		validation.add(path, "id starts with reserved prefix %s", RESERVED_PREFIX)
+	}
+	if device.Name == "" {
+		validation.add(path, "empty name")
+	}
+	if err := device.Type.Valid(); err != nil {
+		validation.add(path, err.Error())
+	}
+	for _, control := range device.Controls {
+		if strings.HasPrefix(string(control), RESERVED_PREFIX) {
+			validation.add(path, "control %s starts with reserved prefix %s", control, RESERVED_PREFIX)
+		}
+	}
+	moduleIds := make([]ModuleId, 0, len(device.Modules))
+	for _, module := range device.Modules {
+		modulePath := fmt.Sprintf("%s/module %s", path, module.Id)
+		if slices.Contains(moduleIds, module.Id) {
+			validation.add(modulePath, "duplicate module id")
+		}
+		moduleIds = append(moduleIds, module.Id)
+		validation.validateModule(modulePath, module)
+	}
+}
+
+func (validation *ValidationError) validateModule(path string, module moduleSnapshot) {
+	if module.Id == "" {
+		validation.add(path, "empty id")
+	}
+	if module.Name == "" {
+		validation.add(path, "empty name")
+	}
+	if err := module.Type.Valid(); err != nil {
+		validation.add(path, err.Error())
+	}
+	ioletIds := make([]IOletId, 0, len(module.IOlets))
+	for _, iolet := range module.IOlets {
+		ioletPath := fmt.Sprintf("%s/iolet %s", path, iolet.Id)
+		if slices.Contains(ioletIds, iolet.Id) {
+			validation.add(ioletPath, "duplicate iolet id")
+		}
+		ioletIds = append(ioletIds, iolet.Id)
+		if iolet.Id == "" {
+			validation.add(ioletPath, "empty id")
+		}
+		if iolet.Name == "" {
+			validation.add(ioletPath, "empty name")
+		}
+		if err := iolet.Type.Valid(); err != nil {
+			validation.add(ioletPath, err.Error())
+		}
+	}
+}
+
+var (
+	moduleTypes = []ModuleType{ModuleType_AV, ModuleType_GPIO, ModuleType_BB, ModuleType_POWER}
+	ioletTypes  = []IOletType{
+		IOletType_IPVIDEOIN,
+		IOletType_IPVIDEOOUT,
+		IOletType_IPAUDIOIN,
+		IOletType_IPAUDIOOUT,
+		IOletType_IPDATA,
+		IOletType_IPTIMING,
+		IOletType_IPGPIO,
+		IOletType_IPGPO,
+		IOletType_BBVIDEOIN,
+		IOletType_BBVIDEOOUT,
+		IOletType_BBAUDIOIN,
+		IOletType_BBAUDIOOUT,
+		IOletType_BBTIMING,
+		IOletType_BBGPIO,
+		IOletType_BBGPO,
+	}
+)
+
+func (moduleType ModuleType) Valid() error {
+	if slices.Contains(moduleTypes, moduleType) {
+		return nil
+	}
+	return fmt.Errorf("%q is not a valid module type", moduleType)
+}
+
+func (ioletType IOletType) Valid() error {
+	if slices.Contains(ioletTypes, ioletType) {
+		return nil
+	}
+	return fmt.Errorf("%q is not a valid iolet type", ioletType)
+}
@@ -0,0 +1,48 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	if err := Validate(diffTestTree()); err != nil {
+		t.Errorf("valid tree should pass, got %v", err)
+	}
+
+	device := NewDevice("1", "UNKNOWN", "Encoder")
+	module := NewModule("av", ModuleType_AV, "Channel 1")
+	module.AddIOlet(NewIOlet("1", IOletType_IPVIDEOIN, "Video In"))
+	device.AddModule(module)
+	// AddIOlet and AddModule replace elements with the same id, other
+	// implementations of the interfaces may keep both
+	module.(*moduleImpl).IOlets = append(module.(*moduleImpl).IOlets, NewIOlet("1", IOletType_IPVIDEOOUT, ""))
+	device.(*deviceImpl).Modules = append(device.(*deviceImpl).Modules, NewModule("av", "MIXER", "Channel 2"))
+	reserved := NewDevice("_jobs", DeviceType__GENERIC_DUMMY, "Jobs")
+	reserved.AddAction("_routes", func(ctx context.Context, device Device) error { return nil })
+	devices := []Device{device, NewDevice("1", DeviceType__GENERIC_DUMMY, "Decoder"), reserved}
+
+	var validation *ValidationError
+	if err := Validate(devices); !errors.As(err, &validation) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	expected := []Problem{
+		{Path: "device 1", Message: `"UNKNOWN" is not a valid device type`},
+		{Path: "device 1/module av/iolet 1", Message: "duplicate iolet id"},
+		{Path: "device 1/module av/iolet 1", Message: "empty name"},
+		{Path: "device 1/module av", Message: "duplicate module id"},
+		{Path: "device 1/module av", Message: `"MIXER" is not a valid module type`},
+		{Path: "device 1", Message: "duplicate device id"},
+		{Path: "device _jobs", Message: "id starts with reserved prefix _"},
+		{Path: "device _jobs", Message: "control _routes starts with reserved prefix _"},
+	}
+	if len(validation.Problems) != len(expected) {
+		t.Fatalf("expected %d problems, got %v", len(expected), validation)
+	}
+	for i := range expected {
+		if validation.Problems[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], validation.Problems[i])
+		}
+	}
+}